@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/storage"
+)
+
+// PlanNodeKind 表示执行计划节点的类型
+type PlanNodeKind string
+
+const (
+	PlanCondition PlanNodeKind = "condition"  // 单个条件
+	PlanAnd       PlanNodeKind = "and"        // 与
+	PlanOr        PlanNodeKind = "or"         // 或
+	PlanNot       PlanNodeKind = "not"        // 非
+	PlanTimeRange PlanNodeKind = "time-range" // 时间范围过滤
+	PlanScan      PlanNodeKind = "scan"       // 无条件扫描
+)
+
+// AccessPath 表示节点获取候选记录的方式
+type AccessPath string
+
+const (
+	AccessExact       AccessPath = "exact"        // 精确索引（map）
+	AccessPrefix      AccessPath = "prefix"       // 前缀索引（trie）
+	AccessSubstring   AccessPath = "substring"    // 子串倒排索引
+	AccessNgram       AccessPath = "ngram"        // n-gram 索引
+	AccessOrdered     AccessPath = "ordered"      // 有序索引（跳表）
+	AccessFullScan    AccessPath = "full-scan"    // 遍历全部记录并调用提取器
+	AccessFilter      AccessPath = "filter"       // 在候选集上逐条用提取器校验
+	AccessIntersect   AccessPath = "intersect"    // 子节点求交集
+	AccessUnion       AccessPath = "union"        // 子节点求并集
+	AccessDifference  AccessPath = "difference"   // 存活记录减去子节点
+	AccessTimeIndex   AccessPath = "time-index"   // Store 的时间索引
+	AccessAliveList   AccessPath = "alive-list"   // 按偏移直接读取存活记录
+	AccessAliveScan   AccessPath = "alive-scan"   // 遍历存活记录并保留前 k 条
+	AccessHistoryScan AccessPath = "history-scan" // 遍历全部记录在指定时刻的状态（AsOf）
+	AccessUnknown     AccessPath = "unsupported"  // 字段没有可用的索引
+)
+
+// PlanNode 是执行计划中的一个节点
+type PlanNode struct {
+	Kind      PlanNodeKind // 节点类型
+	Condition string       // 条件描述，非条件节点为空
+	Access    AccessPath   // 访问路径
+	Order     int          // 在兄弟节点中的执行顺序（从 1 开始），根节点为 0
+	Estimated int          // 执行前估算的匹配数
+	Actual    int          // 实际匹配数，-1 表示因前序结果为空而未执行
+	Remaining int          // 作为“与”的子节点时，执行完该步后剩余的候选数；其他情况为 -1
+	Children  []*PlanNode  // 子节点，按执行顺序排列
+}
+
+// Plan 是一次查询的执行计划及实际执行情况
+type Plan struct {
+	Root      *PlanNode     // 条件树，无条件查询时为扫描节点
+	TimeRange *PlanNode     // 时间范围过滤，未设置时为 nil
+	Matched   int           // 分页前匹配的记录数
+	Returned  int           // 分页后返回的记录数
+	Elapsed   time.Duration // 执行耗时
+}
+
+// Explain 执行查询并返回执行计划，包含每个条件的访问路径、估算与实际匹配数以及求交集的顺序。
+// 参数:
+//   - ctx: 上下文，用于控制查询超时和取消
+//
+// 返回:
+//   - *Plan: 执行计划
+//   - error: 查询过程中的错误
+func (q *Query[T]) Explain(ctx context.Context) (*Plan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	start := time.Now()
+	results, err := q.executeQuery(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	plan.Elapsed = time.Since(start)
+	plan.Returned = len(results)
+	return plan, nil
+}
+
+// String 返回便于写入日志的多行文本形式
+func (p *Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "matched=%d returned=%d elapsed=%s\n", p.Matched, p.Returned, p.Elapsed)
+	if p.Root != nil {
+		p.Root.write(&b, 0)
+	}
+	if p.TimeRange != nil {
+		p.TimeRange.write(&b, 0)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// String 返回节点及其子节点的文本形式
+func (n *PlanNode) String() string {
+	var b strings.Builder
+	n.write(&b, 0)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// write 按缩进写出节点
+func (n *PlanNode) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	if n.Order > 0 {
+		fmt.Fprintf(b, "#%d ", n.Order)
+	}
+	b.WriteString(string(n.Kind))
+	if n.Condition != "" {
+		fmt.Fprintf(b, " %s", n.Condition)
+	}
+	fmt.Fprintf(b, " [%s] est=%d", n.Access, n.Estimated)
+	if n.Actual < 0 {
+		b.WriteString(" skipped")
+	} else {
+		fmt.Fprintf(b, " actual=%d", n.Actual)
+	}
+	if n.Remaining >= 0 {
+		fmt.Fprintf(b, " remaining=%d", n.Remaining)
+	}
+	b.WriteString("\n")
+
+	for _, child := range n.Children {
+		child.write(b, depth+1)
+	}
+}
+
+// describeNode 生成节点的计划描述（不含子节点），实际匹配数和剩余候选数初始化为 -1
+func (q *Query[T]) describeNode(node *queryNode, total int) *PlanNode {
+	pn := &PlanNode{
+		Estimated: q.estimateNode(node, total),
+		Actual:    -1,
+		Remaining: -1,
+	}
+	switch node.kind {
+	case nodeCondition:
+		pn.Kind = PlanCondition
+		pn.Condition = node.cond.String()
+		pn.Access = q.accessPath(node.cond)
+	case nodeAnd:
+		pn.Kind = PlanAnd
+		pn.Access = AccessIntersect
+	case nodeOr:
+		pn.Kind = PlanOr
+		pn.Access = AccessUnion
+	case nodeNot:
+		pn.Kind = PlanNot
+		pn.Access = AccessDifference
+	}
+	return pn
+}
+
+// traceChild 在 trace 下追加子节点的计划描述，trace 为 nil 时返回 nil
+func (q *Query[T]) traceChild(trace *PlanNode, node *queryNode, order int) *PlanNode {
+	if trace == nil {
+		return nil
+	}
+	child := q.describeNode(node, q.store.AliveCount())
+	child.Order = order
+	trace.Children = append(trace.Children, child)
+	return child
+}
+
+// accessPath 返回条件实际会使用的索引，与 processCondition 中的选择逻辑一致
+func (q *Query[T]) accessPath(cond queryCondition) AccessPath {
+	im := q.store.IndexManager
+	field := cond.field
+
+	switch cond.operator {
+	case opEquals, opIn:
+		// 前缀、子串和唯一索引同样维护等值查找表
+		if im.HasIndexType(field, storage.IndexExact) {
+			return AccessExact
+		}
+	case opContains:
+		switch {
+		case im.HasIndexType(field, storage.IndexNgram):
+			return AccessNgram
+		case im.HasIndexType(field, storage.IndexSubstring):
+			return AccessSubstring
+		case im.HasIndexType(field, storage.IndexPrefix):
+			return AccessPrefix
+		}
+	case opBetween, opGt, opGte, opLt, opLte:
+		if im.HasIndexType(field, storage.IndexOrdered) {
+			return AccessOrdered
+		}
+		if _, ok := im.GetExtractor(field); ok {
+			return AccessFullScan
+		}
+	}
+	return AccessUnknown
+}
+
+// describeTimeRange 返回时间范围条件的文本描述
+func (q *Query[T]) describeTimeRange() string {
+	field := "CreatedAt"
+	if q.timeRange.field == storage.TimeUpdated {
+		field = "UpdatedAt"
+	}
+	start, end := "-inf", "+inf"
+	if q.timeRange.start != nil {
+		start = q.timeRange.start.Format(time.RFC3339Nano)
+	}
+	if q.timeRange.end != nil {
+		end = q.timeRange.end.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%s in [%s, %s]", field, start, end)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	stderrors "errors"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/storage"
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// DefaultModifyRetries Modify 遇到版本冲突时的最大重试次数
+const DefaultModifyRetries = 10
+
+// Modify 以乐观并发方式执行“读取-修改-写回”：读取记录后调用 fn 计算新数据，
+// 再用 UpdateIfVersion 写回；写回前记录被其他操作修改时重新读取并重试。
+// fn 可能被调用多次，不应有副作用；fn 收到的是数据的副本（通过 types.CloneData 复制）。需要存储启用版本控制。
+// 参数:
+//   - ctx: 上下文，用于取消重试
+//   - store: 数据存储实例
+//   - id: 记录ID
+//   - fn: 根据当前数据计算新数据，返回错误时放弃修改
+//
+// 返回:
+//   - *types.Record[T]: 更新后的记录
+//   - error: fn 返回的错误、记录不存在，或重试 DefaultModifyRetries 次后仍冲突时的 ErrVersionConflict
+func Modify[T any](ctx context.Context, store *storage.Store[T], id uint64, fn func(T) (T, error)) (*types.Record[T], error) {
+	for attempt := 0; attempt < DefaultModifyRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		current, err := store.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		// 未开启安全读模式时 Get 返回的是内部记录，交给 fn 的是副本，
+		// fn 修改后返回错误也不会改动存储中的数据（与 Tx.Get 相同）
+		version, data := current.Version, types.CloneData(current.Data)
+
+		next, err := fn(data)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := store.UpdateIfVersion(ctx, id, version, next)
+		if stderrors.Is(err, errors.ErrVersionConflict) {
+			continue
+		}
+		return updated, err
+	}
+	return nil, errors.ErrVersionConflict
+}
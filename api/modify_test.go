@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModifyRetriesOnConflict(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().SetVersioning(true).Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	rec, err := store.Insert(ctx, person{Name: "a", Age: 1})
+	require.NoError(t, err)
+
+	// 第一次调用 fn 时有并发写入，Modify 重新读取后在最新数据上重试
+	calls := 0
+	updated, err := Modify(ctx, store, rec.ID, func(p person) (person, error) {
+		calls++
+		if calls == 1 {
+			_, err := store.Update(ctx, rec.ID, person{Name: "a", Age: 10})
+			require.NoError(t, err)
+		}
+		p.Age++
+		return p, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 11, updated.Data.Age)
+
+	// fn 返回错误时放弃修改
+	boom := stderrors.New("boom")
+	_, err = Modify(ctx, store, rec.ID, func(p person) (person, error) {
+		return p, boom
+	})
+	assert.ErrorIs(t, err, boom)
+
+	// 一直冲突时重试 DefaultModifyRetries 次后放弃
+	calls = 0
+	_, err = Modify(ctx, store, rec.ID, func(p person) (person, error) {
+		calls++
+		_, err := store.Update(ctx, rec.ID, p)
+		require.NoError(t, err)
+		return p, nil
+	})
+	assert.ErrorIs(t, err, errors.ErrVersionConflict)
+	assert.Equal(t, DefaultModifyRetries, calls)
+
+	_, err = Modify(ctx, store, 999, func(p person) (person, error) { return p, nil })
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// tagged 含有切片字段，通过 Cloner 深拷贝
+type tagged struct {
+	Tags []string
+}
+
+func (t tagged) Clone() tagged {
+	return tagged{Tags: append([]string(nil), t.Tags...)}
+}
+
+func TestModifyPassesCopyToFn(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[tagged]().SetVersioning(true).Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	rec, err := store.Insert(ctx, tagged{Tags: []string{"a"}})
+	require.NoError(t, err)
+
+	// fn 修改收到的数据后放弃，存储中的记录不受影响
+	boom := stderrors.New("boom")
+	_, err = Modify(ctx, store, rec.ID, func(v tagged) (tagged, error) {
+		v.Tags[0] = "changed"
+		return v, boom
+	})
+	assert.ErrorIs(t, err, boom)
+
+	got, err := store.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, got.Data.Tags)
+}
@@ -1,5 +1,7 @@
 package api
 
+import "fmt"
+
 // operator 定义查询操作符（包内私有）
 type operator string
 
@@ -21,6 +23,42 @@ type queryCondition struct {
 	value    interface{} // 比较值
 }
 
+// String 返回条件的文本描述，用于执行计划
+func (c queryCondition) String() string {
+	switch c.operator {
+	case opContains:
+		return fmt.Sprintf("%s %s %q", c.field, c.operator, c.value)
+	case opBetween:
+		if bounds, ok := c.value.([]interface{}); ok && len(bounds) == 2 {
+			return fmt.Sprintf("%s %s [%v, %v]", c.field, c.operator, bounds[0], bounds[1])
+		}
+	}
+	return fmt.Sprintf("%s %s %v", c.field, c.operator, c.value)
+}
+
+// orderKey 表示一个排序键（包内私有）
+type orderKey struct {
+	field string // 字段名
+	desc  bool   // 是否降序
+}
+
+// nodeKind 表示查询树节点类型（包内私有）
+type nodeKind int
+
+const (
+	nodeCondition nodeKind = iota // 单个条件
+	nodeAnd                       // 与：子节点结果求交集
+	nodeOr                        // 或：子节点结果求并集
+	nodeNot                       // 非：存活记录减去子节点结果
+)
+
+// queryNode 表示布尔查询树中的一个节点（包内私有）
+type queryNode struct {
+	kind     nodeKind
+	cond     queryCondition // 仅 nodeCondition 使用
+	children []*queryNode   // nodeAnd/nodeOr 为任意个，nodeNot 为一个
+}
+
 // 增加类型检查辅助函数
 func isNumeric(v interface{}) bool {
 	switch v.(type) {
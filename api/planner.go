@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ldChengYi/EasyDB/core/storage"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/ldChengYi/EasyDB/util"
+)
+
+// filterThreshold 候选集不超过该大小时，剩余条件改为逐条读取记录、用提取器校验，
+// 不再查询索引并求交集
+const filterThreshold = 64
+
+// evalAnd 按估算的选择度从小到大计算“与”节点的子条件。
+// 参数:
+//   - ctx: 上下文
+//   - children: 子节点
+//   - trace: 非 nil 时按执行顺序记录子节点
+//
+// 返回:
+//   - map[uint64]struct{}: 匹配的记录ID集合（只读）
+//   - error: 处理过程中的错误
+func (q *Query[T]) evalAnd(ctx context.Context, children []*queryNode, trace *PlanNode) (map[uint64]struct{}, error) {
+	if len(children) == 0 {
+		return q.view.IDs(), nil
+	}
+
+	ordered := q.planAnd(children)
+
+	var result map[uint64]struct{}
+	for i, child := range ordered {
+		if i > 0 && len(result) <= filterThreshold {
+			filtered, err := q.filterIDs(ctx, result, ordered[i:])
+			if err != nil {
+				return nil, err
+			}
+			for j, rest := range ordered[i:] {
+				if childTrace := q.traceChild(trace, rest, i+j+1); childTrace != nil {
+					childTrace.Access = AccessFilter
+					childTrace.Actual = len(filtered)
+					childTrace.Remaining = len(filtered)
+				}
+			}
+			return filtered, nil
+		}
+
+		childTrace := q.traceChild(trace, child, i+1)
+		matches, err := q.evalNode(ctx, child, childTrace)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			result = matches
+		} else {
+			result = intersectIDs(result, matches)
+		}
+		if childTrace != nil {
+			childTrace.Remaining = len(result)
+		}
+		if len(result) == 0 {
+			// 结果已为空，剩余条件不再执行
+			for j, rest := range ordered[i+1:] {
+				q.traceChild(trace, rest, i+j+2)
+			}
+			break
+		}
+	}
+	return result, nil
+}
+
+// planAnd 返回按估算匹配数升序排列的子节点（新切片，不修改查询树）
+func (q *Query[T]) planAnd(children []*queryNode) []*queryNode {
+	total := q.store.AliveCount()
+	estimates := make(map[*queryNode]int, len(children))
+	for _, child := range children {
+		estimates[child] = q.estimateNode(child, total)
+	}
+
+	ordered := make([]*queryNode, len(children))
+	copy(ordered, children)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return estimates[ordered[i]] < estimates[ordered[j]]
+	})
+	return ordered
+}
+
+// estimateNode 根据索引倒排表大小估算节点匹配的记录数。
+// 参数:
+//   - node: 查询树节点
+//   - total: 存活记录总数，无法使用索引估算时的上界
+//
+// 返回:
+//   - int: 估算的匹配记录数
+func (q *Query[T]) estimateNode(node *queryNode, total int) int {
+	switch node.kind {
+	case nodeCondition:
+		return q.estimateCondition(node.cond, total)
+	case nodeAnd:
+		min := total
+		for _, child := range node.children {
+			if n := q.estimateNode(child, total); n < min {
+				min = n
+			}
+		}
+		return min
+	case nodeOr:
+		sum := 0
+		for _, child := range node.children {
+			sum += q.estimateNode(child, total)
+		}
+		if sum > total {
+			sum = total
+		}
+		return sum
+	case nodeNot:
+		return total - q.estimateNode(node.children[0], total)
+	default:
+		return total
+	}
+}
+
+// estimateCondition 估算单个条件匹配的记录数，条件无效时返回 0 以便尽早报错。
+// 参数:
+//   - cond: 查询条件
+//   - total: 存活记录总数
+//
+// 返回:
+//   - int: 估算的匹配记录数
+func (q *Query[T]) estimateCondition(cond queryCondition, total int) int {
+	im := q.store.IndexManager
+
+	switch cond.operator {
+	case opEquals:
+		key, err := q.equalKey(cond)
+		if err != nil {
+			return 0
+		}
+		if n, ok := im.Estimate(cond.field, key); ok {
+			return n
+		}
+	case opIn:
+		values, ok := cond.value.([]interface{})
+		if !ok {
+			return 0
+		}
+		sum := 0
+		for _, v := range values {
+			n, ok := im.Estimate(cond.field, v)
+			if !ok {
+				return total
+			}
+			sum += n
+		}
+		return sum
+	case opContains:
+		valStr, err := util.SafeToString(cond.value)
+		if err != nil {
+			return 0
+		}
+		if n, ok := im.EstimateContains(cond.field, valStr); ok {
+			return n
+		}
+	case opBetween, opGt, opGte, opLt, opLte:
+		lo, hi, includeLo, includeHi, err := q.rangeKeys(cond)
+		if err != nil {
+			return 0
+		}
+		if n, ok := im.EstimateRange(cond.field, lo, hi, includeLo, includeHi, total); ok {
+			return n
+		}
+	}
+	return total
+}
+
+// filterIDs 逐条读取候选记录（不计为读取），用提取器校验剩余条件。
+// 参数:
+//   - ctx: 上下文
+//   - ids: 候选记录ID集合
+//   - nodes: 需要校验的条件，全部满足才保留
+//
+// 返回:
+//   - map[uint64]struct{}: 满足条件的记录ID集合
+//   - error: 处理过程中的错误
+func (q *Query[T]) filterIDs(ctx context.Context, ids map[uint64]struct{}, nodes []*queryNode) (map[uint64]struct{}, error) {
+	result := make(map[uint64]struct{}, len(ids))
+	for id := range ids {
+		record := q.view.Peek(id)
+		if record == nil {
+			continue
+		}
+
+		matched := true
+		for _, node := range nodes {
+			ok, err := q.matchRecord(node, record)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result[id] = struct{}{}
+		}
+	}
+	return result, nil
+}
+
+// matchRecord 判断记录是否满足查询树节点。
+// 参数:
+//   - node: 查询树节点
+//   - record: 要校验的记录
+//
+// 返回:
+//   - bool: 是否满足
+//   - error: 处理过程中的错误
+func (q *Query[T]) matchRecord(node *queryNode, record *types.Record[T]) (bool, error) {
+	switch node.kind {
+	case nodeCondition:
+		return q.matchCondition(node.cond, record)
+	case nodeAnd:
+		for _, child := range node.children {
+			ok, err := q.matchRecord(child, record)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case nodeOr:
+		for _, child := range node.children {
+			ok, err := q.matchRecord(child, record)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case nodeNot:
+		ok, err := q.matchRecord(node.children[0], record)
+		return !ok, err
+	default:
+		return false, fmt.Errorf("unsupported query node: %d", node.kind)
+	}
+}
+
+// matchCondition 用字段提取器校验单个条件，语义与对应的索引查询一致。
+// 参数:
+//   - cond: 查询条件
+//   - record: 要校验的记录
+//
+// 返回:
+//   - bool: 是否满足
+//   - error: 处理过程中的错误
+func (q *Query[T]) matchCondition(cond queryCondition, record *types.Record[T]) (bool, error) {
+	im := q.store.IndexManager
+	extractor, ok := im.GetExtractor(cond.field)
+	if !ok {
+		return false, fmt.Errorf("field extractor not found for field: %s", cond.field)
+	}
+	val := extractor(record)
+
+	// 匹配方式只取决于字段注册的索引类型，不依赖索引当前的内容，
+	// 因此同样适用于读视图和 AsOf 中的历史版本
+	switch cond.operator {
+	case opEquals:
+		key, err := q.equalKey(cond)
+		if err != nil {
+			return false, err
+		}
+		return storage.MatchExact.Match(val, key), nil
+
+	case opIn:
+		values, ok := cond.value.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("in operator requires a slice value, got %T", cond.value)
+		}
+		if err := q.checkEqualityIndex(cond.field); err != nil {
+			return false, err
+		}
+		for _, v := range values {
+			if storage.MatchExact.Match(val, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case opContains:
+		sub, err := q.containsKey(cond)
+		if err != nil {
+			return false, err
+		}
+		return im.ContainsMode(cond.field).Match(val, sub), nil
+
+	case opBetween, opGt, opGte, opLt, opLte:
+		lo, hi, includeLo, includeHi, err := q.rangeKeys(cond)
+		if err != nil {
+			return false, err
+		}
+		ok, err := inRange(val, lo, hi, includeLo, includeHi)
+		if err != nil {
+			return false, fmt.Errorf("field %s: %w", cond.field, err)
+		}
+		return ok, nil
+
+	default:
+		return false, fmt.Errorf("unsupported operator: %s", cond.operator)
+	}
+}
@@ -11,18 +11,31 @@ import (
 	"github.com/ldChengYi/EasyDB/util"
 )
 
+// 内置排序字段，未注册同名索引字段时可用于 OrderBy
+const (
+	FieldID        = "_id"        // 记录 ID
+	FieldCreatedAt = "_createdAt" // 记录创建时间
+	FieldUpdatedAt = "_updatedAt" // 记录更新时间
+)
+
+// FieldKey 主键字段，配置 StoreBuilder.SetPrimaryKey 后可用于 Where 等值查询和 OrderBy
+const FieldKey = storage.PrimaryKeyField
+
 // Query 是一个泛型查询构建器，支持链式调用的查询语法。
 // 它提供了丰富的查询条件、排序和分页功能。
 // 泛型参数 T 可以是任意结构体类型。
 type Query[T any] struct {
-	store      *storage.Store[T]
-	conditions []queryCondition
-	limit      int
-	offset     int
-	orderBy    string
-	orderDesc  bool
-	timeRange  struct {
-		start, end int64
+	store     *storage.Store[T]
+	view      *storage.ReadView[T] // 查询使用的读视图，为 nil 时每次执行打开一个新视图
+	asOf      *time.Time           // 非 nil 时查询记录在该时刻的状态
+	nodes     []*queryNode         // 顶层条件，按“与”组合
+	limit     int
+	offset    int
+	orderKeys []orderKey // 排序键，按添加顺序依次比较
+	timeRange struct {
+		enabled    bool
+		field      storage.TimeField
+		start, end *time.Time // nil 表示该侧无界
 	}
 }
 
@@ -34,9 +47,9 @@ type Query[T any] struct {
 //   - 新的查询构建器实例，默认限制为100条记录
 func NewQuery[T any](store *storage.Store[T]) *Query[T] {
 	return &Query[T]{
-		store:      store,
-		conditions: make([]queryCondition, 0),
-		limit:      100,
+		store: store,
+		nodes: make([]*queryNode, 0),
+		limit: 100,
 	}
 }
 
@@ -53,6 +66,69 @@ func (q *Query[T]) Where(field string) *FieldQuery[T] {
 	}
 }
 
+// Group 创建一个与当前查询共享存储的空条件组，用于构造 Or、Not、And 的子条件。
+// 条件组内的条件按“与”组合，其 Limit、Offset、OrderBy 等设置不生效。
+// 返回:
+//   - 新的条件组
+func (q *Query[T]) Group() *Query[T] {
+	return NewQuery(q.store)
+}
+
+// Or 添加“或”条件，任一条件组满足即匹配。
+// 参数:
+//   - groups: 条件组列表，通常由 Group 创建
+//
+// 返回:
+//   - 查询构建器实例，用于链式调用
+func (q *Query[T]) Or(groups ...*Query[T]) *Query[T] {
+	node := &queryNode{kind: nodeOr}
+	for _, g := range groups {
+		node.children = append(node.children, g.asNode())
+	}
+	q.nodes = append(q.nodes, node)
+	return q
+}
+
+// Not 添加“非”条件，排除满足条件组的记录（相对于全部存活记录计算）。
+// 参数:
+//   - group: 要排除的条件组，通常由 Group 创建
+//
+// 返回:
+//   - 查询构建器实例，用于链式调用
+func (q *Query[T]) Not(group *Query[T]) *Query[T] {
+	q.nodes = append(q.nodes, &queryNode{
+		kind:     nodeNot,
+		children: []*queryNode{group.asNode()},
+	})
+	return q
+}
+
+// And 添加嵌套的“与”条件，所有条件组都满足才匹配。
+// 参数:
+//   - groups: 条件组列表，通常由 Group 创建
+//
+// 返回:
+//   - 查询构建器实例，用于链式调用
+func (q *Query[T]) And(groups ...*Query[T]) *Query[T] {
+	for _, g := range groups {
+		q.nodes = append(q.nodes, g.asNode())
+	}
+	return q
+}
+
+// addCondition 追加一个顶层条件
+func (q *Query[T]) addCondition(cond queryCondition) {
+	q.nodes = append(q.nodes, &queryNode{kind: nodeCondition, cond: cond})
+}
+
+// asNode 将查询的顶层条件转换为一个“与”节点
+func (q *Query[T]) asNode() *queryNode {
+	if len(q.nodes) == 1 {
+		return q.nodes[0]
+	}
+	return &queryNode{kind: nodeAnd, children: q.nodes}
+}
+
 // FieldQuery 是字段查询构建器，用于构建特定字段的查询条件。
 type FieldQuery[T any] struct {
 	query *Query[T]
@@ -66,7 +142,7 @@ type FieldQuery[T any] struct {
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (fq *FieldQuery[T]) Equals(value interface{}) *Query[T] {
-	fq.query.conditions = append(fq.query.conditions, queryCondition{
+	fq.query.addCondition(queryCondition{
 		field:    fq.field,
 		operator: opEquals,
 		value:    value,
@@ -81,7 +157,7 @@ func (fq *FieldQuery[T]) Equals(value interface{}) *Query[T] {
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (fq *FieldQuery[T]) Contains(value string) *Query[T] {
-	fq.query.conditions = append(fq.query.conditions, queryCondition{
+	fq.query.addCondition(queryCondition{
 		field:    fq.field,
 		operator: opContains,
 		value:    value,
@@ -96,7 +172,7 @@ func (fq *FieldQuery[T]) Contains(value string) *Query[T] {
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (fq *FieldQuery[T]) In(values ...interface{}) *Query[T] {
-	fq.query.conditions = append(fq.query.conditions, queryCondition{
+	fq.query.addCondition(queryCondition{
 		field:    fq.field,
 		operator: opIn,
 		value:    values,
@@ -112,7 +188,7 @@ func (fq *FieldQuery[T]) In(values ...interface{}) *Query[T] {
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (fq *FieldQuery[T]) Between(start, end interface{}) *Query[T] {
-	fq.query.conditions = append(fq.query.conditions, queryCondition{
+	fq.query.addCondition(queryCondition{
 		field:    fq.field,
 		operator: opBetween,
 		value:    []interface{}{start, end},
@@ -127,7 +203,7 @@ func (fq *FieldQuery[T]) Between(start, end interface{}) *Query[T] {
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (fq *FieldQuery[T]) GreaterThan(value interface{}) *Query[T] {
-	fq.query.conditions = append(fq.query.conditions, queryCondition{
+	fq.query.addCondition(queryCondition{
 		field:    fq.field,
 		operator: opGt,
 		value:    value,
@@ -142,7 +218,7 @@ func (fq *FieldQuery[T]) GreaterThan(value interface{}) *Query[T] {
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (fq *FieldQuery[T]) GreaterThanOrEqual(value interface{}) *Query[T] {
-	fq.query.conditions = append(fq.query.conditions, queryCondition{
+	fq.query.addCondition(queryCondition{
 		field:    fq.field,
 		operator: opGte,
 		value:    value,
@@ -157,7 +233,7 @@ func (fq *FieldQuery[T]) GreaterThanOrEqual(value interface{}) *Query[T] {
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (fq *FieldQuery[T]) LessThan(value interface{}) *Query[T] {
-	fq.query.conditions = append(fq.query.conditions, queryCondition{
+	fq.query.addCondition(queryCondition{
 		field:    fq.field,
 		operator: opLt,
 		value:    value,
@@ -172,7 +248,7 @@ func (fq *FieldQuery[T]) LessThan(value interface{}) *Query[T] {
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (fq *FieldQuery[T]) LessThanOrEqual(value interface{}) *Query[T] {
-	fq.query.conditions = append(fq.query.conditions, queryCondition{
+	fq.query.addCondition(queryCondition{
 		field:    fq.field,
 		operator: opLte,
 		value:    value,
@@ -202,29 +278,78 @@ func (q *Query[T]) Offset(offset int) *Query[T] {
 	return q
 }
 
-// OrderBy 设置排序规则。
+// OrderBy 添加排序规则，多次调用时按调用顺序作为第一、第二……排序键，
+// 所有键都相等时按记录 ID 升序排列，保证分页结果稳定。
 // 参数:
-//   - field: 排序字段
+//   - field: 排序字段，可以是已注册的索引字段，或 FieldID、FieldCreatedAt、FieldUpdatedAt
 //   - desc: 是否降序排序
 //
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (q *Query[T]) OrderBy(field string, desc bool) *Query[T] {
-	q.orderBy = field
-	q.orderDesc = desc
+	q.orderKeys = append(q.orderKeys, orderKey{field: field, desc: desc})
 	return q
 }
 
-// InTimeRange 设置时间范围过滤。
+// InTimeRange 按记录创建时间（RecordMeta.CreatedAt）过滤，范围为闭区间。
+// 可以单独使用，无需 Where 条件。
 // 参数:
-//   - start: 起始时间
-//   - end: 结束时间
+//   - start: 起始时间，零值表示不限制
+//   - end: 结束时间，零值表示不限制
 //
 // 返回:
 //   - 查询构建器实例，用于链式调用
 func (q *Query[T]) InTimeRange(start, end time.Time) *Query[T] {
-	q.timeRange.start = start.UnixNano()
-	q.timeRange.end = end.UnixNano()
+	return q.setTimeRange(storage.TimeCreated, start, end)
+}
+
+// InUpdatedTimeRange 按记录更新时间（RecordMeta.UpdatedAt）过滤，范围为闭区间。
+// 参数:
+//   - start: 起始时间，零值表示不限制
+//   - end: 结束时间，零值表示不限制
+//
+// 返回:
+//   - 查询构建器实例，用于链式调用
+func (q *Query[T]) InUpdatedTimeRange(start, end time.Time) *Query[T] {
+	return q.setTimeRange(storage.TimeUpdated, start, end)
+}
+
+// InView 在指定的读视图上执行查询，多个查询共用一个视图时看到的是同一时刻的数据。
+// 视图由调用方负责关闭；不设置时每次执行都在执行开始时打开一个新视图。
+// 参数:
+//   - view: Store.ReadView 返回的读视图
+//
+// 返回:
+//   - 查询构建器实例，用于链式调用
+func (q *Query[T]) InView(view *storage.ReadView[T]) *Query[T] {
+	q.view = view
+	return q
+}
+
+// AsOf 查询记录在过去某一时刻的状态，需要通过 StoreBuilder.SetHistory 保留旧版本。
+// 历史状态不受之后写入的影响，此时不使用读视图；索引只反映当前状态，因此会遍历全部记录逐条校验。
+// 当时的版本已不在保留的历史中的记录不会出现在结果中。
+// 参数:
+//   - t: 查询的时刻
+//
+// 返回:
+//   - 查询构建器实例，用于链式调用
+func (q *Query[T]) AsOf(t time.Time) *Query[T] {
+	q.asOf = &t
+	return q
+}
+
+// setTimeRange 设置时间范围过滤条件
+func (q *Query[T]) setTimeRange(field storage.TimeField, start, end time.Time) *Query[T] {
+	q.timeRange.enabled = true
+	q.timeRange.field = field
+	q.timeRange.start, q.timeRange.end = nil, nil
+	if !start.IsZero() {
+		q.timeRange.start = &start
+	}
+	if !end.IsZero() {
+		q.timeRange.end = &end
+	}
 	return q
 }
 
@@ -249,7 +374,7 @@ func (q *Query[T]) Do(ctx context.Context) ([]*types.Record[T], error) {
 	})
 
 	go func() {
-		results, err := q.executeQuery(queryCtx)
+		results, err := q.executeQuery(queryCtx, nil)
 		done <- struct {
 			results []*types.Record[T]
 			err     error
@@ -264,47 +389,375 @@ func (q *Query[T]) Do(ctx context.Context) ([]*types.Record[T], error) {
 	}
 }
 
-// executeQuery 执行实际的查询操作。
+// executeQuery 在读视图上执行查询，未通过 InView 指定视图时打开一个临时视图。
 // 参数:
 //   - ctx: 上下文，用于控制查询超时和取消
+//   - plan: 非 nil 时记录执行计划（Explain 使用）
 //
 // 返回:
 //   - []*types.Record[T]: 查询结果记录列表
 //   - error: 查询过程中的错误
-func (q *Query[T]) executeQuery(ctx context.Context) ([]*types.Record[T], error) {
+func (q *Query[T]) executeQuery(ctx context.Context, plan *Plan) ([]*types.Record[T], error) {
+	if q.asOf != nil {
+		return q.executeAsOf(ctx, plan)
+	}
+	if q.view != nil {
+		return q.execute(ctx, plan)
+	}
+
+	view := q.store.ReadView()
+	defer view.Close()
+
+	run := *q
+	run.view = view
+	return run.execute(ctx, plan)
+}
+
+// execute 执行实际的查询操作（q.view 非 nil）。
+// 索引反映的是当前状态，视图打开之后被修改过的记录需要按视图中的版本重新校验，
+// 其余记录在视图中的状态与当前相同，可以直接使用索引的结果。
+// 参数:
+//   - ctx: 上下文，用于控制查询超时和取消
+//   - plan: 非 nil 时记录执行计划（Explain 使用）
+//
+// 返回:
+//   - []*types.Record[T]: 查询结果记录列表
+//   - error: 查询过程中的错误
+func (q *Query[T]) execute(ctx context.Context, plan *Plan) ([]*types.Record[T], error) {
+	// 没有任何条件时直接扫描存活记录
+	if len(q.nodes) == 0 && !q.timeRange.enabled {
+		return q.executeFullScan(ctx, plan)
+	}
+
 	var results []*types.Record[T]
 	var matchedIDs map[uint64]struct{}
 
-	for i, cond := range q.conditions {
-		currentMatches, err := q.processCondition(ctx, cond)
+	if len(q.nodes) > 0 {
+		root := q.asNode()
+		var trace *PlanNode
+		if plan != nil {
+			trace = q.describeNode(root, q.store.AliveCount())
+			plan.Root = trace
+		}
+
+		var err error
+		matchedIDs, err = q.evalNode(ctx, root, trace)
 		if err != nil {
-			return nil, fmt.Errorf("failed to process condition: %w", err)
+			return nil, err
 		}
+	}
 
-		if i == 0 {
-			matchedIDs = currentMatches
-		} else {
-			for id := range matchedIDs {
-				if _, ok := currentMatches[id]; !ok {
-					delete(matchedIDs, id)
-				}
+	// 时间范围通过 Store 维护的时间索引过滤
+	if q.timeRange.enabled {
+		inRange := q.store.QueryTimeRange(q.timeRange.field, q.timeRange.start, q.timeRange.end)
+		if plan != nil {
+			plan.TimeRange = &PlanNode{
+				Kind:      PlanTimeRange,
+				Condition: q.describeTimeRange(),
+				Access:    AccessTimeIndex,
+				Estimated: len(inRange),
+				Actual:    len(inRange),
+				Remaining: -1,
 			}
 		}
+		if len(q.nodes) > 0 {
+			matchedIDs = intersectIDs(matchedIDs, inRange)
+		} else {
+			matchedIDs = inRange
+		}
+	}
+
+	if err := q.recheckChanged(ctx, matchedIDs); err != nil {
+		return nil, err
 	}
 
+	// 排序分页之前只读取内部记录，只有最终返回的一页计为读取并复制
 	for id := range matchedIDs {
-		if record, err := q.store.Get(ctx, id); err == nil {
+		if record := q.view.Peek(id); record != nil {
 			results = append(results, record)
 		}
 	}
 
-	if q.orderBy != "" {
-		// if err := q.sortResults(results); err != nil {
-		// 	return nil, fmt.Errorf("failed to sort results: %w", err)
-		// }
+	if err := q.sortResults(results); err != nil {
+		return nil, fmt.Errorf("failed to sort results: %w", err)
+	}
+	if plan != nil {
+		plan.Matched = len(results)
+	}
+
+	page, err := q.applyPagination(results)
+	if err != nil {
+		return nil, err
+	}
+	return q.exportPage(ctx, page), nil
+}
+
+// exportPage 通过视图的 Get 取得可交给调用方的记录，并将它们计为读取
+func (q *Query[T]) exportPage(ctx context.Context, page []*types.Record[T]) []*types.Record[T] {
+	records := make([]*types.Record[T], 0, len(page))
+	for _, r := range page {
+		if record, err := q.view.Get(ctx, r.ID); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// executeAsOf 遍历记录在 q.asOf 时刻的状态，逐条校验所有条件后排序分页。
+// 参数:
+//   - ctx: 上下文，用于控制查询超时和取消
+//   - plan: 非 nil 时记录执行计划（Explain 使用）
+//
+// 返回:
+//   - []*types.Record[T]: 查询结果记录列表
+//   - error: 查询过程中的错误
+func (q *Query[T]) executeAsOf(ctx context.Context, plan *Plan) ([]*types.Record[T], error) {
+	var results []*types.Record[T]
+	var scanErr error
+	scanned := 0
+	q.store.ScanAsOf(*q.asOf, func(r *types.Record[T]) bool {
+		scanned++
+		if scanned%1024 == 0 {
+			if scanErr = ctx.Err(); scanErr != nil {
+				return false
+			}
+		}
+		ok, err := q.matchAll(r)
+		if err != nil {
+			scanErr = err
+			return false
+		}
+		if ok {
+			results = append(results, r)
+		}
+		return true
+	})
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	if err := q.sortResults(results); err != nil {
+		return nil, fmt.Errorf("failed to sort results: %w", err)
+	}
+	if plan != nil {
+		plan.Root = &PlanNode{Kind: PlanScan, Access: AccessHistoryScan, Estimated: scanned, Actual: len(results), Remaining: -1}
+		plan.Matched = len(results)
+	}
+
+	page, err := q.applyPagination(results)
+	if err != nil {
+		return nil, err
+	}
+	// ScanAsOf 交出的是内部记录，通过 GetAsOf 取得可交给调用方的记录
+	records := make([]*types.Record[T], 0, len(page))
+	for _, r := range page {
+		if record, err := q.store.GetAsOf(ctx, r.ID, *q.asOf); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// executeFullScan 执行无条件查询。
+// 没有排序键时通过 ReadView.List 按 ID 升序读取，记录在存储中按 ID 排列时为 O(offset+limit)；
+// 有排序键时遍历一次存活记录，用大小为 offset+limit 的堆保留前 k 条。
+// 参数:
+//   - ctx: 上下文，用于控制查询超时和取消
+//   - plan: 非 nil 时记录执行计划（Explain 使用）
+//
+// 返回:
+//   - []*types.Record[T]: 查询结果记录列表
+//   - error: 查询过程中的错误
+func (q *Query[T]) executeFullScan(ctx context.Context, plan *Plan) ([]*types.Record[T], error) {
+	if q.offset < 0 {
+		return nil, fmt.Errorf("offset cannot be negative")
+	}
+	if q.limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	if len(q.orderKeys) == 0 {
+		records, total, err := q.view.List(ctx, q.offset, q.limit)
+		if plan != nil {
+			plan.Root = &PlanNode{Kind: PlanScan, Access: AccessAliveList, Estimated: total, Actual: len(records), Remaining: -1}
+			plan.Matched = total
+		}
+		return records, err
+	}
+
+	keyFuncs, err := q.sortKeyFuncs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sort results: %w", err)
+	}
+
+	top := &topK[T]{k: q.offset + q.limit, less: q.lessItem}
+	var scanErr error
+	scanned := 0
+	q.view.Scan(func(r *types.Record[T]) bool {
+		scanned++
+		if scanned%1024 == 0 {
+			if scanErr = ctx.Err(); scanErr != nil {
+				return false
+			}
+		}
+		item, err := q.makeSortItem(r, keyFuncs)
+		if err != nil {
+			scanErr = fmt.Errorf("failed to sort results: %w", err)
+			return false
+		}
+		top.offer(item)
+		return true
+	})
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	if plan != nil {
+		plan.Root = &PlanNode{Kind: PlanScan, Access: AccessAliveScan, Estimated: scanned, Actual: scanned, Remaining: -1}
+		plan.Matched = scanned
+	}
+
+	items := top.sorted()
+	if q.offset >= len(items) {
+		return make([]*types.Record[T], 0), nil
+	}
+	// Scan 交出的是内部记录，通过 Get 取得可交给调用方的记录
+	results := make([]*types.Record[T], 0, len(items)-q.offset)
+	for _, item := range items[q.offset:] {
+		if record, err := q.view.Get(ctx, item.record.ID); err == nil {
+			results = append(results, record)
+		}
+	}
+	return results, nil
+}
+
+// evalNode 递归计算查询树节点匹配的记录ID集合。
+// 集合运算一律生成新集合，不修改输入。
+// 参数:
+//   - ctx: 上下文
+//   - node: 查询树节点
+//   - trace: 非 nil 时记录该节点的实际匹配数和子节点的执行情况
+//
+// 返回:
+//   - map[uint64]struct{}: 匹配的记录ID集合（只读）
+//   - error: 处理过程中的错误
+func (q *Query[T]) evalNode(ctx context.Context, node *queryNode, trace *PlanNode) (map[uint64]struct{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var result map[uint64]struct{}
+	switch node.kind {
+	case nodeCondition:
+		matches, err := q.processCondition(ctx, node.cond)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process condition: %w", err)
+		}
+		result = matches
+
+	case nodeAnd:
+		matches, err := q.evalAnd(ctx, node.children, trace)
+		if err != nil {
+			return nil, err
+		}
+		result = matches
+
+	case nodeOr:
+		result = make(map[uint64]struct{})
+		for i, child := range node.children {
+			childTrace := q.traceChild(trace, child, i+1)
+			matches, err := q.evalNode(ctx, child, childTrace)
+			if err != nil {
+				return nil, err
+			}
+			for id := range matches {
+				result[id] = struct{}{}
+			}
+		}
+
+	case nodeNot:
+		childTrace := q.traceChild(trace, node.children[0], 1)
+		excluded, err := q.evalNode(ctx, node.children[0], childTrace)
+		if err != nil {
+			return nil, err
+		}
+		result = q.view.IDs()
+		for id := range excluded {
+			delete(result, id)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported query node: %d", node.kind)
+	}
+
+	if trace != nil {
+		trace.Actual = len(result)
 	}
+	return result, nil
+}
 
-	return q.applyPagination(results)
+// recheckChanged 按视图中的版本重新校验视图打开之后被修改过的记录，直接修改 matched。
+// 参数:
+//   - ctx: 上下文
+//   - matched: 根据当前索引计算出的匹配记录ID集合
+//
+// 返回:
+//   - error: 处理过程中的错误
+func (q *Query[T]) recheckChanged(ctx context.Context, matched map[uint64]struct{}) error {
+	for id := range q.view.Changed() {
+		delete(matched, id)
+
+		record := q.view.Peek(id)
+		if record == nil {
+			continue
+		}
+		ok, err := q.matchAll(record)
+		if err != nil {
+			return err
+		}
+		if ok {
+			matched[id] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// matchAll 判断记录是否满足所有顶层条件和时间范围
+func (q *Query[T]) matchAll(record *types.Record[T]) (bool, error) {
+	for _, node := range q.nodes {
+		ok, err := q.matchRecord(node, record)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if !q.timeRange.enabled {
+		return true, nil
+	}
+
+	ts := record.Meta.CreatedAt
+	if q.timeRange.field == storage.TimeUpdated {
+		ts = record.Meta.UpdatedAt
+	}
+	if q.timeRange.start != nil && ts < q.timeRange.start.UnixNano() {
+		return false, nil
+	}
+	if q.timeRange.end != nil && ts > q.timeRange.end.UnixNano() {
+		return false, nil
+	}
+	return true, nil
+}
+
+// intersectIDs 返回两个集合的交集（新集合），遍历较小的一方
+func intersectIDs(a, b map[uint64]struct{}) map[uint64]struct{} {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	result := make(map[uint64]struct{}, len(a))
+	for id := range a {
+		if _, ok := b[id]; ok {
+			result[id] = struct{}{}
+		}
+	}
+	return result
 }
 
 // processCondition 处理单个查询条件。
@@ -331,6 +784,30 @@ func (q *Query[T]) processCondition(ctx context.Context, cond queryCondition) (m
 }
 
 func (q *Query[T]) processEqualCondition(cond queryCondition) (map[uint64]struct{}, error) {
+	convertedVal, err := q.equalKey(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := q.store.IndexManager.Query(cond.field, convertedVal)
+	if matches == nil {
+		return make(map[uint64]struct{}), nil
+	}
+	return matches, nil
+}
+
+// equalKey 将等值条件的比较值转换为字段提取器的返回类型。
+// 参数:
+//   - cond: 等值查询条件
+//
+// 返回:
+//   - interface{}: 转换后的值，可直接用于精确索引查找
+//   - error: 字段未索引或类型无法转换时的错误
+func (q *Query[T]) equalKey(cond queryCondition) (interface{}, error) {
+	if err := q.checkEqualityIndex(cond.field); err != nil {
+		return nil, err
+	}
+
 	fts := q.store.IndexManager.GetFieldTypes()
 	ft, ok := fts[cond.field]
 	if !ok {
@@ -341,12 +818,21 @@ func (q *Query[T]) processEqualCondition(cond queryCondition) (map[uint64]struct
 	if err != nil {
 		return nil, fmt.Errorf("type conversion failed: %v", err)
 	}
+	return convertedVal, nil
+}
 
-	matches := q.store.IndexManager.Query(cond.field, convertedVal)
-	if matches == nil {
-		return make(map[uint64]struct{}), nil
+// checkEqualityIndex 检查字段是否注册了可用于等值查询的索引（精确、唯一、前缀或子串，均按完整值相等匹配）。
+// n-gram 索引只服务 Contains，有序索引只服务范围查询。
+// 参数:
+//   - field: 字段名
+//
+// 返回:
+//   - error: 字段没有可用于等值查询的索引时的错误
+func (q *Query[T]) checkEqualityIndex(field string) error {
+	if q.store.IndexManager.HasIndexType(field, storage.IndexExact) {
+		return nil
 	}
-	return matches, nil
+	return fmt.Errorf("field %s does not support exact, prefix or substring index", field)
 }
 
 // processContainCondition 处理 Contain 条件。
@@ -360,24 +846,53 @@ func (q *Query[T]) processContainCondition(cond queryCondition) (map[uint64]stru
 	im := q.store.IndexManager
 	field := cond.field
 
-	// 转为 string，用于 prefix/substring 匹配
-	valStr, err := util.SafeToString(cond.value)
+	valStr, err := q.containsKey(cond)
 	if err != nil {
-		return nil, fmt.Errorf("field %s: value not string-convertible: %w", field, err)
+		return nil, err
 	}
 
-	// 优先使用前缀索引
-	if result := im.QueryPrefix(field, valStr); result != nil {
-		return result, nil
+	// 匹配方式与 matchCondition 逐条校验时相同，见 IndexManager.ContainsMode
+	var result map[uint64]struct{}
+	switch {
+	case im.HasIndexType(field, storage.IndexNgram):
+		// n-gram 索引的结果已按原始值校验
+		result = im.QueryNgram(field, valStr)
+	case im.HasIndexType(field, storage.IndexSubstring):
+		result = im.QuerySubstring(field, valStr)
+	default:
+		result = im.QueryPrefix(field, valStr)
 	}
 
-	// 再使用子串倒排索引
-	if result := im.QuerySubstring(field, valStr); result != nil {
-		return result, nil
+	// 索引存在但没有匹配项
+	if result == nil {
+		return make(map[uint64]struct{}), nil
 	}
+	return result, nil
+}
 
-	// 如果该字段没注册相关索引，返回错误
-	return nil, fmt.Errorf("field %s does not support prefix or substring index", field)
+// containsKey 将 Contains 条件的比较值转换为字符串，并检查字段是否注册了可用的索引。
+// 参数:
+//   - cond: Contains 查询条件
+//
+// 返回:
+//   - string: 用于前缀/子串匹配的字符串
+//   - error: 值无法转换或字段没有 n-gram、前缀、子串索引时的错误
+func (q *Query[T]) containsKey(cond queryCondition) (string, error) {
+	im := q.store.IndexManager
+	field := cond.field
+
+	// 转为 string，用于 prefix/substring 匹配
+	valStr, err := util.SafeToString(cond.value)
+	if err != nil {
+		return "", fmt.Errorf("field %s: value not string-convertible: %w", field, err)
+	}
+
+	if !im.HasIndexType(field, storage.IndexNgram) && !im.HasIndexType(field, storage.IndexPrefix) &&
+		!im.HasIndexType(field, storage.IndexSubstring) {
+		// 如果该字段没注册相关索引，返回错误
+		return "", fmt.Errorf("field %s does not support ngram, prefix or substring index", field)
+	}
+	return valStr, nil
 }
 
 // processInCondition 处理 IN 条件。
@@ -401,25 +916,19 @@ func (q *Query[T]) processInCondition(cond queryCondition) (map[uint64]struct{},
 	if _, ok := im.GetIndexes()[field]; !ok {
 		return nil, fmt.Errorf("no index found for field %s", field)
 	}
+	if err := q.checkEqualityIndex(field); err != nil {
+		return nil, err
+	}
 
 	result := make(map[uint64]struct{})
-	foundAny := false
 
 	for i := 0; i < val.Len(); i++ {
 		item := val.Index(i).Interface()
-		set := im.Query(field, item)
-		if set != nil {
-			foundAny = true
-			for id := range set {
-				result[id] = struct{}{}
-			}
+		for id := range im.Query(field, item) {
+			result[id] = struct{}{}
 		}
 	}
 
-	if !foundAny {
-		return nil, fmt.Errorf("no matching entries found in 'IN' condition for field %s", field)
-	}
-
 	return result, nil
 }
 
@@ -432,48 +941,124 @@ func (q *Query[T]) processInCondition(cond queryCondition) (map[uint64]struct{},
 //   - map[uint64]struct{}: 匹配的记录ID集合
 //   - error: 处理过程中的错误
 func (q *Query[T]) processRangeCondition(ctx context.Context, cond queryCondition) (map[uint64]struct{}, error) {
+	lo, hi, includeLo, includeHi, err := q.rangeKeys(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	// 优先使用有序索引，O(log n + k)
+	if result, ok, err := q.store.IndexManager.QueryRange(cond.field, lo, hi, includeLo, includeHi); ok {
+		return result, err
+	}
+
 	result := make(map[uint64]struct{})
 	fieldExtractor, ok := q.store.IndexManager.GetExtractor(cond.field)
 	if !ok {
 		return nil, fmt.Errorf("field extractor not found for field: %s", cond.field)
 	}
 
-	all := q.store.Data()
-	for _, r := range all {
-		val := fieldExtractor(r)
+	q.view.Scan(func(r *types.Record[T]) bool {
+		var ok bool
+		ok, err = inRange(fieldExtractor(r), lo, hi, includeLo, includeHi)
+		if err != nil {
+			return false
+		}
+		if ok {
+			result[r.ID] = struct{}{}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", cond.field, err)
+	}
 
-		switch cond.operator {
-		case opBetween:
-			// between 要求是 [min, max] 两个元素
-			bounds, ok := cond.value.([]interface{})
-			if !ok || len(bounds) != 2 {
-				return nil, fmt.Errorf("between requires [min, max] slice")
-			}
-			if util.Compare(val, bounds[0]) >= 0 && util.Compare(val, bounds[1]) <= 0 {
-				result[r.ID] = struct{}{}
-			}
-		case opGt:
-			if util.Compare(val, cond.value) > 0 {
-				result[r.ID] = struct{}{}
-			}
-		case opGte:
-			if util.Compare(val, cond.value) >= 0 {
-				result[r.ID] = struct{}{}
-			}
-		case opLt:
-			if util.Compare(val, cond.value) < 0 {
-				result[r.ID] = struct{}{}
-			}
-		case opLte:
-			if util.Compare(val, cond.value) <= 0 {
-				result[r.ID] = struct{}{}
+	return result, nil
+}
+
+// rangeKeys 解析范围条件的上下界，并按 GetFieldTypes 记录的字段类型校验边界能否与字段值比较，
+// 避免类型不符的边界（如用数字查询 time.Time 字段）在索引中比较失败。
+// 参数:
+//   - cond: 范围查询条件
+//
+// 返回:
+//   - lo, hi: 下界和上界，nil 表示该侧无界
+//   - includeLo, includeHi: 是否包含边界
+//   - err: 条件格式错误或边界类型与字段不符
+func (q *Query[T]) rangeKeys(cond queryCondition) (lo, hi interface{}, includeLo, includeHi bool, err error) {
+	lo, hi, includeLo, includeHi, err = rangeBounds(cond)
+	if err != nil {
+		return nil, nil, false, false, err
+	}
+
+	ft := q.store.IndexManager.GetFieldTypes()[cond.field]
+	for _, bound := range []interface{}{lo, hi} {
+		if bound == nil {
+			continue
+		}
+		if !util.CanCompare(bound) {
+			return nil, nil, false, false, fmt.Errorf("field %s: range bound of type %T is not comparable", cond.field, bound)
+		}
+		// 提取器声明了具体类型时直接校验，返回 interface{} 时由索引或逐条比较时校验
+		if ft != nil && ft.Kind() != reflect.Interface {
+			if _, err := util.TryCompare(reflect.Zero(ft).Interface(), bound); err != nil {
+				return nil, nil, false, false, fmt.Errorf("field %s: range bound: %w", cond.field, err)
 			}
-		default:
-			return nil, fmt.Errorf("unsupported operator: %s", cond.operator)
 		}
 	}
+	return lo, hi, includeLo, includeHi, nil
+}
+
+// rangeBounds 解析范围条件的上下界，nil 表示该侧无界。
+// 参数:
+//   - cond: 范围查询条件
+//
+// 返回:
+//   - lo, hi: 下界和上界
+//   - includeLo, includeHi: 是否包含边界
+//   - err: 条件格式错误
+func rangeBounds(cond queryCondition) (lo, hi interface{}, includeLo, includeHi bool, err error) {
+	switch cond.operator {
+	case opBetween:
+		// between 要求是 [min, max] 两个元素
+		bounds, ok := cond.value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return nil, nil, false, false, fmt.Errorf("between requires [min, max] slice")
+		}
+		return bounds[0], bounds[1], true, true, nil
+	case opGt:
+		return cond.value, nil, false, false, nil
+	case opGte:
+		return cond.value, nil, true, false, nil
+	case opLt:
+		return nil, cond.value, false, false, nil
+	case opLte:
+		return nil, cond.value, false, true, nil
+	default:
+		return nil, nil, false, false, fmt.Errorf("unsupported operator: %s", cond.operator)
+	}
+}
 
-	return result, nil
+// inRange 判断值是否落在范围内，值与边界无法比较时返回错误
+func inRange(val, lo, hi interface{}, includeLo, includeHi bool) (bool, error) {
+	if lo != nil {
+		c, err := util.TryCompare(val, lo)
+		if err != nil {
+			return false, err
+		}
+		if c < 0 || (c == 0 && !includeLo) {
+			return false, nil
+		}
+	}
+	if hi != nil {
+		c, err := util.TryCompare(val, hi)
+		if err != nil {
+			return false, err
+		}
+		if c > 0 || (c == 0 && !includeHi) {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
 // applyPagination 应用分页。
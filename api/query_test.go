@@ -0,0 +1,575 @@
+package api
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/storage"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type person struct {
+	Name string
+	Age  int
+	At   time.Time
+}
+
+func TestRangeBoundTypeMismatch(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().
+		AddIndex("At", func(r *types.Record[person]) interface{} { return r.Data.At }, storage.IndexOrdered).
+		AddIndex("Age", func(r *types.Record[person]) interface{} { return r.Data.Age }).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := store.Insert(ctx, person{Name: "a", Age: i, At: now.Add(time.Duration(i) * time.Hour)})
+		require.NoError(t, err)
+	}
+
+	// 有序索引路径
+	_, err = NewQuery(store).Where("At").GreaterThan(5).Do(ctx)
+	assert.Error(t, err)
+
+	// 无有序索引时逐条比较的路径
+	_, err = NewQuery(store).Where("Age").Between("a", "z").Do(ctx)
+	assert.Error(t, err)
+
+	records, err := NewQuery(store).Where("At").GreaterThan(now).Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	records, err = NewQuery(store).Where("Age").GreaterThanOrEqual(1.5).Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestNgramOnlyFieldRejectsEquality(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().
+		AddIndex("Name", func(r *types.Record[person]) interface{} { return r.Data.Name }, storage.IndexNgram).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	for _, name := range []string{"alice", "malice", "bob"} {
+		_, err := store.Insert(ctx, person{Name: name})
+		require.NoError(t, err)
+	}
+
+	records, err := NewQuery(store).Where("Name").Contains("lic").Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	_, err = NewQuery(store).Where("Name").Equals("lic").Do(ctx)
+	assert.Error(t, err)
+	_, err = NewQuery(store).Where("Name").In("alice").Do(ctx)
+	assert.Error(t, err)
+}
+
+func TestFilterPassMatchesIndexSemantics(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().
+		AddIndex("Name", func(r *types.Record[person]) interface{} { return r.Data.Name }, storage.IndexPrefix).
+		AddIndex("Age", func(r *types.Record[person]) interface{} { return r.Data.Age }, storage.IndexExact).
+		AddIndex("Tag", func(r *types.Record[person]) interface{} { return r.Data.Name }, storage.IndexPrefix, storage.IndexSubstring).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	for _, name := range []string{"Alice", "Sal"} {
+		_, err = store.Insert(ctx, person{Name: name, Age: 30})
+		require.NoError(t, err)
+	}
+	for i := 0; i < 200; i++ {
+		_, err := store.Insert(ctx, person{Name: "Alfred", Age: 40})
+		require.NoError(t, err)
+	}
+
+	// Equals 总是按完整值匹配；Age 的候选集很小时 Name 走逐条校验，较大时走索引，结果相同
+	records, err := NewQuery(store).Where("Name").Equals("Al").Where("Age").Equals(30).Do(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	records, err = NewQuery(store).Where("Name").Equals("Alice").Where("Age").Equals(30).Do(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Alice", records[0].Data.Name)
+	records, err = NewQuery(store).Where("Name").Equals("Alfred").Where("Age").Equals(40).Limit(1000).Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 200)
+
+	// 只有前缀索引时 Contains 按前缀匹配，注册了子串索引时按子串匹配
+	records, err = NewQuery(store).Where("Name").Contains("al").Where("Age").Equals(30).Do(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	records, err = NewQuery(store).Where("Tag").Contains("Al").Where("Age").Equals(30).Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+	records, err = NewQuery(store).Where("Tag").Contains("al").Where("Age").Equals(30).Do(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "Sal", records[0].Data.Name)
+	records, err = NewQuery(store).Where("Tag").Contains("l").Limit(1000).Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 202)
+}
+
+func TestViewMatchesOverwrittenValues(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().
+		AddIndex("Name", func(r *types.Record[person]) interface{} { return r.Data.Name }, storage.IndexPrefix).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	rec, err := store.Insert(ctx, person{Name: "TCP"})
+	require.NoError(t, err)
+
+	view := store.ReadView()
+	defer view.Close()
+	_, err = store.Update(ctx, rec.ID, person{Name: "UDP"})
+	require.NoError(t, err)
+
+	// 当前索引中已没有 TCP，视图中的旧版本仍按注册的索引类型匹配
+	records, err := NewQuery(store).InView(view).Where("Name").Equals("TCP").Do(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "TCP", records[0].Data.Name)
+
+	records, err = NewQuery(store).InView(view).Where("Name").Contains("TC").Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	records, err = NewQuery(store).InView(view).Where("Name").Equals("UDP").Do(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestQueryResultsRefreshLRU(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().
+		SetCapacityLimit(2, 0, storage.EvictLeastRecentlyRead).
+		AddIndex("Name", func(r *types.Record[person]) interface{} { return r.Data.Name }, storage.IndexExact).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	a, err := store.Insert(ctx, person{Name: "a"})
+	require.NoError(t, err)
+	b, err := store.Insert(ctx, person{Name: "b"})
+	require.NoError(t, err)
+
+	records, err := NewQuery(store).Where("Name").Equals("a").Do(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	_, err = store.Insert(ctx, person{Name: "c"})
+	require.NoError(t, err)
+
+	_, err = store.Get(ctx, a.ID)
+	assert.NoError(t, err, "record read by a query should not be evicted first")
+	_, err = store.Get(ctx, b.ID)
+	assert.Error(t, err)
+}
+
+func TestAsOfMatchesOverwrittenValues(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().
+		SetHistory(4).
+		AddIndex("Name", func(r *types.Record[person]) interface{} { return r.Data.Name }, storage.IndexPrefix, storage.IndexSubstring).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	rec, err := store.Insert(ctx, person{Name: "open"})
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+	_, err = store.Update(ctx, rec.ID, person{Name: "closed"})
+	require.NoError(t, err)
+
+	// 当前已没有记录的值为 open，过去时刻的查询仍能匹配当时的状态
+	records, err := NewQuery(store).Where("Name").Equals("open").AsOf(before).Do(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "open", records[0].Data.Name)
+
+	records, err = NewQuery(store).Where("Name").Contains("pe").AsOf(before).Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	records, err = NewQuery(store).Where("Name").Equals("closed").AsOf(before).Do(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestQueryTouchesOnlyReturnedPage(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().
+		SetCapacityLimit(3, 0, storage.EvictLeastRecentlyRead).
+		AddIndex("Age", func(r *types.Record[person]) interface{} { return r.Data.Age }, storage.IndexExact).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	var ids []uint64
+	for _, name := range []string{"a", "b", "c"} {
+		rec, err := store.Insert(ctx, person{Name: name, Age: 1})
+		require.NoError(t, err)
+		ids = append(ids, rec.ID)
+	}
+
+	// b、c 同样匹配，但不在返回的一页中，不应刷新淘汰顺序
+	records, err := NewQuery(store).Where("Age").Equals(1).OrderBy(FieldID, false).Limit(1).Do(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "a", records[0].Data.Name)
+
+	_, err = store.Insert(ctx, person{Name: "d", Age: 2})
+	require.NoError(t, err)
+
+	_, err = store.Get(ctx, ids[1])
+	assert.Error(t, err, "b was read least recently")
+	for _, id := range []uint64{ids[0], ids[2]} {
+		_, err = store.Get(ctx, id)
+		assert.NoError(t, err)
+	}
+}
+
+func TestFullScanReturnsIDOrder(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	// 事务插入时分配 ID，提交时才追加，位置晚于之后直接插入的记录
+	tx := store.Begin()
+	_, err = tx.Insert(ctx, person{Name: "tx"})
+	require.NoError(t, err)
+	_, err = store.Insert(ctx, person{Name: "plain"})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+
+	ids := func(records []*types.Record[person]) []uint64 {
+		out := make([]uint64, len(records))
+		for i, r := range records {
+			out[i] = r.ID
+		}
+		return out
+	}
+
+	records, err := NewQuery(store).Do(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, ids(records))
+
+	records, err = NewQuery(store).InTimeRange(time.Unix(0, 0), time.Now().Add(time.Hour)).Do(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, ids(records))
+
+	records, err = NewQuery(store).Offset(1).Limit(1).Do(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2}, ids(records))
+
+	// Compact 之后按 ID 重新排列，可以直接按位置分页
+	store.Compact()
+	records, _, err = store.List(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, ids(records))
+	records, err = NewQuery(store).Do(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, ids(records))
+}
+
+func TestSafeReadsReturnCopies(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[tagged]().
+		SetSafeReads(true).
+		AddIndex("Tag", func(r *types.Record[tagged]) interface{} { return r.Data.Tags[0] }, storage.IndexExact).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	// 写入时复制，调用方之后修改传入的数据不影响存储
+	tags := []string{"a"}
+	rec, err := store.Insert(ctx, tagged{Tags: tags})
+	require.NoError(t, err)
+	tags[0] = "caller"
+	rec.Data.Tags[0] = "returned"
+	rec.Meta.Deleted = true
+
+	got, err := store.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, got.Data.Tags)
+	assert.False(t, got.Meta.Deleted)
+
+	// 各个读取路径返回的都是副本
+	got.Data.Tags[0] = "get"
+	list, _, err := store.List(ctx, 0, 10)
+	require.NoError(t, err)
+	list[0].Data.Tags[0] = "list"
+	store.Data()[0].Data.Tags[0] = "data"
+	records, err := NewQuery(store).Where("Tag").Equals("a").Do(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	records[0].Data.Tags[0] = "query"
+
+	got, err = store.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, got.Data.Tags)
+	records, err = NewQuery(store).Where("Tag").Equals("a").Do(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 1, "index must still match the stored value")
+}
+
+type packet struct {
+	Proto string
+	Port  int
+	Size  int
+}
+
+// newPacketStore 创建带 Proto、Port（精确）和 Size（有序）索引的存储并写入 packets
+func newPacketStore(t *testing.T, packets ...packet) *storage.Store[packet] {
+	t.Helper()
+	store, err := NewStoreBuilder[packet]().
+		AddIndex("Proto", func(r *types.Record[packet]) interface{} { return r.Data.Proto }, storage.IndexExact).
+		AddIndex("Port", func(r *types.Record[packet]) interface{} { return r.Data.Port }, storage.IndexExact).
+		AddIndex("Size", func(r *types.Record[packet]) interface{} { return r.Data.Size }, storage.IndexOrdered).
+		Build()
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	for _, p := range packets {
+		_, err := store.Insert(context.Background(), p)
+		require.NoError(t, err)
+	}
+	return store
+}
+
+// packetPorts 按顺序返回记录的 Port
+func packetPorts(records []*types.Record[packet]) []int {
+	out := make([]int, len(records))
+	for i, r := range records {
+		out[i] = r.Data.Port
+	}
+	return out
+}
+
+func TestOrNotGroups(t *testing.T) {
+	ctx := context.Background()
+	store := newPacketStore(t,
+		packet{Proto: "TCP", Port: 80, Size: 100},
+		packet{Proto: "TCP", Port: 443, Size: 200},
+		packet{Proto: "UDP", Port: 53, Size: 300},
+		packet{Proto: "ICMP", Port: 0, Size: 400},
+	)
+
+	tests := []struct {
+		name  string
+		build func(q *Query[packet]) *Query[packet]
+		want  []int
+	}{
+		{
+			name: "or",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.Or(q.Group().Where("Proto").Equals("TCP"), q.Group().Where("Proto").Equals("UDP"))
+			},
+			want: []int{80, 443, 53},
+		},
+		{
+			name: "or and not",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.Or(q.Group().Where("Proto").Equals("TCP"), q.Group().Where("Proto").Equals("UDP")).
+					Not(q.Group().Where("Port").Equals(443))
+			},
+			want: []int{80, 53},
+		},
+		{
+			name: "not alone",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.Not(q.Group().Where("Proto").Equals("TCP"))
+			},
+			want: []int{53, 0},
+		},
+		{
+			name: "nested and inside or",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.Or(
+					q.Group().And(q.Group().Where("Proto").Equals("TCP"), q.Group().Where("Size").GreaterThan(150)),
+					q.Group().Where("Proto").Equals("ICMP"),
+				)
+			},
+			want: []int{443, 0},
+		},
+		{
+			name: "not of or",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.Not(q.Group().Or(q.Group().Where("Port").Equals(80), q.Group().Where("Port").Equals(53)))
+			},
+			want: []int{443, 0},
+		},
+		{
+			name: "or with plain condition",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.Where("Size").LessThan(350).
+					Or(q.Group().Where("Port").Equals(53), q.Group().Where("Port").Equals(0))
+			},
+			want: []int{53},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := tt.build(NewQuery(store)).OrderBy(FieldID, false).Do(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, packetPorts(records))
+
+			// 逐条校验（订阅、读视图使用）与索引查询的结果一致
+			for _, r := range store.Data() {
+				ok, err := tt.build(NewQuery(store)).matchAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, slices.Contains(tt.want, r.Data.Port), ok, "port %d", r.Data.Port)
+			}
+		})
+	}
+}
+
+func TestOrderByMultipleKeysWithTies(t *testing.T) {
+	ctx := context.Background()
+	store := newPacketStore(t,
+		packet{Proto: "UDP", Port: 1, Size: 100},
+		packet{Proto: "TCP", Port: 2, Size: 100},
+		packet{Proto: "TCP", Port: 3, Size: 300},
+		packet{Proto: "UDP", Port: 4, Size: 300},
+		packet{Proto: "TCP", Port: 5, Size: 100},
+	)
+
+	tests := []struct {
+		name  string
+		build func(q *Query[packet]) *Query[packet]
+		want  []int
+	}{
+		{
+			name:  "single key ties broken by id",
+			build: func(q *Query[packet]) *Query[packet] { return q.OrderBy("Size", false) },
+			want:  []int{1, 2, 5, 3, 4},
+		},
+		{
+			name:  "descending key ties still ascending id",
+			build: func(q *Query[packet]) *Query[packet] { return q.OrderBy("Size", true) },
+			want:  []int{3, 4, 1, 2, 5},
+		},
+		{
+			name: "second key",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.OrderBy("Proto", false).OrderBy("Size", true)
+			},
+			want: []int{3, 2, 5, 4, 1},
+		},
+		{
+			name: "id descending as last key",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.OrderBy("Size", false).OrderBy(FieldID, true)
+			},
+			want: []int{5, 2, 1, 4, 3},
+		},
+		{
+			name: "with condition and pagination",
+			build: func(q *Query[packet]) *Query[packet] {
+				return q.Where("Proto").Equals("TCP").OrderBy("Size", false).Offset(1).Limit(2)
+			},
+			want: []int{5, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 无条件查询走堆排序，有时间范围时走普通排序，结果一致
+			records, err := tt.build(NewQuery(store)).Do(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, packetPorts(records))
+
+			records, err = tt.build(NewQuery(store)).InTimeRange(time.Unix(0, 0), time.Now().Add(time.Hour)).Do(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, packetPorts(records))
+		})
+	}
+
+	_, err := NewQuery(store).OrderBy("Missing", false).Do(ctx)
+	assert.Error(t, err)
+}
+
+func TestExplain(t *testing.T) {
+	ctx := context.Background()
+	packets := make([]packet, 0, 102)
+	for i := 0; i < 100; i++ {
+		packets = append(packets, packet{Proto: "TCP", Port: 80, Size: i})
+	}
+	packets = append(packets, packet{Proto: "TCP", Port: 443, Size: 5}, packet{Proto: "UDP", Port: 53, Size: 7})
+	store := newPacketStore(t, packets...)
+
+	// 选择度高的条件先执行，候选集足够小后剩余条件改为逐条校验
+	plan, err := NewQuery(store).Where("Proto").Equals("TCP").Where("Port").Equals(443).Explain(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, plan.Matched)
+	assert.Equal(t, 1, plan.Returned)
+	require.Equal(t, PlanAnd, plan.Root.Kind)
+	require.Len(t, plan.Root.Children, 2)
+	first, second := plan.Root.Children[0], plan.Root.Children[1]
+	assert.Equal(t, "Port eq 443", first.Condition)
+	assert.Equal(t, AccessExact, first.Access)
+	assert.Equal(t, 1, first.Order)
+	assert.Equal(t, 1, first.Estimated)
+	assert.Equal(t, "Proto eq TCP", second.Condition)
+	assert.Equal(t, AccessFilter, second.Access)
+	assert.Equal(t, 101, second.Estimated)
+	assert.Equal(t, 1, second.Actual)
+	assert.Equal(t, 1, second.Remaining)
+
+	// 结果为空后剩余条件不再执行
+	plan, err = NewQuery(store).Where("Proto").Equals("XX").Where("Size").LessThan(10).Explain(ctx)
+	require.NoError(t, err)
+	require.Len(t, plan.Root.Children, 2)
+	assert.Equal(t, 0, plan.Root.Children[0].Actual)
+	assert.Equal(t, AccessOrdered, plan.Root.Children[1].Access)
+	assert.Equal(t, -1, plan.Root.Children[1].Actual)
+	assert.Contains(t, plan.String(), "#2 condition Size lt 10 [ordered] est=12 skipped")
+
+	// 或、非节点及其子节点
+	q := NewQuery(store)
+	plan, err = q.Or(q.Group().Where("Port").Equals(53), q.Group().Where("Port").Equals(443)).
+		Not(q.Group().Where("Size").GreaterThan(5)).Explain(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, plan.Matched)
+	require.Len(t, plan.Root.Children, 2)
+	or := plan.Root.Children[0]
+	assert.Equal(t, PlanOr, or.Kind)
+	assert.Equal(t, AccessUnion, or.Access)
+	assert.Len(t, or.Children, 2)
+	assert.Equal(t, PlanNot, plan.Root.Children[1].Kind)
+
+	// 无条件查询和时间范围
+	plan, err = NewQuery(store).Limit(5).Explain(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, PlanScan, plan.Root.Kind)
+	assert.Equal(t, AccessAliveList, plan.Root.Access)
+	assert.Equal(t, 102, plan.Matched)
+	assert.Equal(t, 5, plan.Returned)
+
+	plan, err = NewQuery(store).OrderBy("Size", true).Limit(5).Explain(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, AccessAliveScan, plan.Root.Access)
+
+	plan, err = NewQuery(store).Where("Port").Equals(53).InTimeRange(time.Unix(0, 0), time.Now().Add(time.Hour)).Explain(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, plan.TimeRange)
+	assert.Equal(t, AccessTimeIndex, plan.TimeRange.Access)
+	assert.Equal(t, 102, plan.TimeRange.Actual)
+	assert.Equal(t, 1, plan.Matched)
+}
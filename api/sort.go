@@ -0,0 +1,152 @@
+package api
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/ldChengYi/EasyDB/util"
+)
+
+// sortItem 是带有预提取排序值的记录（包内私有）
+type sortItem[T any] struct {
+	record *types.Record[T]
+	keys   []interface{}
+}
+
+// sortResults 按排序键对结果排序，没有排序键时按记录 ID 升序。
+// 参数:
+//   - results: 要排序的记录列表（原地排序）
+//
+// 返回:
+//   - error: 排序字段不存在或字段值不可比较时的错误
+func (q *Query[T]) sortResults(results []*types.Record[T]) error {
+	if len(q.orderKeys) == 0 {
+		sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+		return nil
+	}
+
+	keyFuncs, err := q.sortKeyFuncs()
+	if err != nil {
+		return err
+	}
+
+	// 预先提取排序值，避免比较时重复调用提取器
+	items := make([]sortItem[T], len(results))
+	for i, r := range results {
+		item, err := q.makeSortItem(r, keyFuncs)
+		if err != nil {
+			return err
+		}
+		items[i] = item
+	}
+
+	sort.Slice(items, func(i, j int) bool { return q.lessItem(&items[i], &items[j]) })
+
+	for i := range items {
+		results[i] = items[i].record
+	}
+	return nil
+}
+
+// sortKeyFuncs 返回所有排序键的取值函数
+func (q *Query[T]) sortKeyFuncs() ([]func(*types.Record[T]) interface{}, error) {
+	keyFuncs := make([]func(*types.Record[T]) interface{}, len(q.orderKeys))
+	for i, key := range q.orderKeys {
+		fn, err := q.orderKeyFunc(key.field)
+		if err != nil {
+			return nil, err
+		}
+		keyFuncs[i] = fn
+	}
+	return keyFuncs, nil
+}
+
+// makeSortItem 提取记录的排序值
+func (q *Query[T]) makeSortItem(r *types.Record[T], keyFuncs []func(*types.Record[T]) interface{}) (sortItem[T], error) {
+	keys := make([]interface{}, len(keyFuncs))
+	for k, fn := range keyFuncs {
+		val := fn(r)
+		if !util.CanCompare(val) {
+			return sortItem[T]{}, fmt.Errorf("field %s: value of type %T is not sortable", q.orderKeys[k].field, val)
+		}
+		keys[k] = val
+	}
+	return sortItem[T]{record: r, keys: keys}, nil
+}
+
+// lessItem 按排序键依次比较，全部相等时按记录 ID 升序
+func (q *Query[T]) lessItem(a, b *sortItem[T]) bool {
+	for k, key := range q.orderKeys {
+		c := util.Compare(a.keys[k], b.keys[k])
+		if c == 0 {
+			continue
+		}
+		if key.desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return a.record.ID < b.record.ID
+}
+
+// orderKeyFunc 返回排序字段的取值函数，已注册的索引字段优先于内置字段。
+// 参数:
+//   - field: 排序字段
+//
+// 返回:
+//   - func(*types.Record[T]) interface{}: 取值函数
+//   - error: 字段不存在时的错误
+func (q *Query[T]) orderKeyFunc(field string) (func(*types.Record[T]) interface{}, error) {
+	if fn, ok := q.store.IndexManager.GetExtractor(field); ok {
+		return fn, nil
+	}
+
+	switch field {
+	case FieldID:
+		return func(r *types.Record[T]) interface{} { return r.ID }, nil
+	case FieldCreatedAt:
+		return func(r *types.Record[T]) interface{} { return r.Meta.CreatedAt }, nil
+	case FieldUpdatedAt:
+		return func(r *types.Record[T]) interface{} { return r.Meta.UpdatedAt }, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errors.ErrFieldNotFound, field)
+}
+
+// topK 保留排序最靠前的 k 条记录，堆顶是当前保留的最靠后的一条（包内私有）
+type topK[T any] struct {
+	k     int
+	less  func(a, b *sortItem[T]) bool
+	items []sortItem[T]
+}
+
+func (t *topK[T]) Len() int           { return len(t.items) }
+func (t *topK[T]) Less(i, j int) bool { return t.less(&t.items[j], &t.items[i]) }
+func (t *topK[T]) Swap(i, j int)      { t.items[i], t.items[j] = t.items[j], t.items[i] }
+func (t *topK[T]) Push(x any)         { t.items = append(t.items, x.(sortItem[T])) }
+func (t *topK[T]) Pop() any {
+	last := t.items[len(t.items)-1]
+	t.items = t.items[:len(t.items)-1]
+	return last
+}
+
+// offer 尝试加入一条记录，比堆顶更靠前时替换堆顶
+func (t *topK[T]) offer(item sortItem[T]) {
+	if len(t.items) < t.k {
+		heap.Push(t, item)
+		return
+	}
+	if t.less(&item, &t.items[0]) {
+		t.items[0] = item
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted 返回按排序规则排好的保留记录
+func (t *topK[T]) sorted() []sortItem[T] {
+	sort.Slice(t.items, func(i, j int) bool { return t.less(&t.items[i], &t.items[j]) })
+	return t.items
+}
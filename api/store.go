@@ -2,6 +2,8 @@ package api
 
 import (
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/ldChengYi/EasyDB/core/storage"
 	"github.com/ldChengYi/EasyDB/core/types"
@@ -13,7 +15,25 @@ import (
 type StoreBuilder[T any] struct {
 	initialCapacity  int
 	enableVersioning bool
+	historyLimit     int
 	indexBuilder     *IndexBuilder[T]
+	primaryKey       func(T) interface{}
+	safeReads        bool
+	walPath          string
+	syncWrites       bool
+	compactLogSize   int64
+	compactInterval  time.Duration
+	tombstoneRatio   float64
+	tombstoneKeep    time.Duration
+	defaultTTL       time.Duration
+	reapInterval     time.Duration
+	maxRecords       int
+	maxBytes         int64
+	eviction         storage.EvictionPolicy
+	recordSize       func(T) int64
+	priority         func(*types.Record[T]) int64
+	onEvict          func(*types.Record[T])
+	onError          func(error)
 	built            bool
 }
 
@@ -50,6 +70,166 @@ func (b *StoreBuilder[T]) SetVersioning(enable bool) *StoreBuilder[T] {
 	return b
 }
 
+// SetHistory 为每条记录保留最近的旧版本，用于 Store.GetVersion、History、GetAsOf 和 Query.AsOf，
+// 需要启用版本控制。历史只保存在内存中，已删除记录的历史在 Compact 回收记录时丢弃。
+// 参数:
+//   - limit: 每条记录保留的旧版本数，<=0 表示不保留
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetHistory(limit int) *StoreBuilder[T] {
+	b.historyLimit = limit
+	return b
+}
+
+// SetSafeReads 设置安全读模式：返回给调用方的记录都是副本，写入的数据也会先复制，
+// 调用方修改它们不会破坏存储和索引。T 含有切片、map 等引用字段时应实现 types.Cloner 以便深拷贝。
+// 参数:
+//   - enable: 是否启用安全读模式
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetSafeReads(enable bool) *StoreBuilder[T] {
+	b.safeReads = enable
+	return b
+}
+
+// SetPrimaryKey 设置主键提取函数，主键在存活记录中唯一，
+// 可通过 Store.GetByKey、UpdateByKey、DeleteByKey 和 Upsert 按主键访问记录。
+// 参数:
+//   - key: 从数据中提取主键的函数，返回值需可作为 map 的键
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetPrimaryKey(key func(T) interface{}) *StoreBuilder[T] {
+	b.primaryKey = key
+	return b
+}
+
+// SetWAL 启用预写日志持久化。
+// 参数:
+//   - path: 日志文件路径，文件已存在时构建时会回放其中的数据
+//   - syncWrites: 是否每次写入后立即 fsync
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetWAL(path string, syncWrites bool) *StoreBuilder[T] {
+	b.walPath = path
+	b.syncWrites = syncWrites
+	return b
+}
+
+// SetLogCompaction 设置后台日志压缩的触发条件，需先通过 SetWAL 启用日志。
+// 参数:
+//   - maxLogSize: 日志超过该字节数时压缩，<=0 表示不按大小触发
+//   - interval: 定时压缩间隔，<=0 表示不定时触发
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetLogCompaction(maxLogSize int64, interval time.Duration) *StoreBuilder[T] {
+	b.compactLogSize = maxLogSize
+	b.compactInterval = interval
+	return b
+}
+
+// SetTombstoneCompaction 设置自动回收已删除记录的阈值，也可手动调用 Store.Compact。
+// 参数:
+//   - ratio: 已删除记录占比超过该值（且至少 1024 条）时在后台压缩，<=0 表示不自动压缩
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetTombstoneCompaction(ratio float64) *StoreBuilder[T] {
+	b.tombstoneRatio = ratio
+	return b
+}
+
+// SetTombstoneRetention 设置已删除记录至少保留的时长，保留期内的记录不会被 Compact 回收，
+// 可以通过 Store.Restore 恢复，Store.Purge 可以提前永久删除；保留期过后由后台任务自动回收。
+// 参数:
+//   - retention: 保留时长，<=0 表示 Compact 回收所有已删除记录
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetTombstoneRetention(retention time.Duration) *StoreBuilder[T] {
+	b.tombstoneKeep = retention
+	return b
+}
+
+// SetTTL 设置记录的默认存活时间，过期记录立即对 Get/List/Query 不可见，并由后台任务定期软删除。
+// 参数:
+//   - defaultTTL: 新记录的默认存活时间，<=0 表示不过期（Store.InsertWithTTL 可单独指定）
+//   - reapInterval: 后台清理过期记录的间隔，<=0 时使用 1 秒
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetTTL(defaultTTL, reapInterval time.Duration) *StoreBuilder[T] {
+	b.defaultTTL = defaultTTL
+	b.reapInterval = reapInterval
+	return b
+}
+
+// SetCapacityLimit 设置存储容量上限，写入后超出上限时按策略淘汰记录（从所有索引中移除）。
+// 参数:
+//   - maxRecords: 存活记录数上限，<=0 表示不限制
+//   - maxBytes: 存活记录估算总字节数上限，<=0 表示不限制（大小估算见 SetRecordSize）
+//   - policy: 淘汰策略，EvictLowestPriority 需同时通过 SetEvictionPriority 设置优先级函数
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetCapacityLimit(maxRecords int, maxBytes int64, policy storage.EvictionPolicy) *StoreBuilder[T] {
+	b.maxRecords = maxRecords
+	b.maxBytes = maxBytes
+	b.eviction = policy
+	return b
+}
+
+// SetRecordSize 设置估算数据大小的函数，用于 MaxBytes 统计，默认使用 JSON 编码后的长度。
+// 参数:
+//   - size: 返回数据估算字节数的函数，同一数据应返回相同的值
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetRecordSize(size func(T) int64) *StoreBuilder[T] {
+	b.recordSize = size
+	return b
+}
+
+// SetEvictionPriority 设置 EvictLowestPriority 策略使用的优先级函数，值小的记录先被淘汰。
+// 参数:
+//   - priority: 返回记录优先级的函数
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) SetEvictionPriority(priority func(*types.Record[T]) int64) *StoreBuilder[T] {
+	b.priority = priority
+	return b
+}
+
+// OnEvict 设置记录被淘汰后的通知函数，可用于把记录转存到别处。
+// 通知在写锁内同步调用，函数中不能调用 Store 的方法，耗时操作应交给其他 goroutine。
+// 参数:
+//   - fn: 接收被淘汰记录（已标记删除）的函数
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) OnEvict(fn func(*types.Record[T])) *StoreBuilder[T] {
+	b.onEvict = fn
+	return b
+}
+
+// OnError 设置接收无法返回给调用方的错误的函数：写入已生效后容量淘汰失败（如写日志失败）、
+// 后台日志压缩或过期清理失败。未设置时通过标准库 log 输出。
+// 函数可能在写锁内同步调用，不能调用 Store 的方法。
+// 参数:
+//   - fn: 接收错误的函数
+//
+// 返回:
+//   - *StoreBuilder[T]: 构建器实例，用于链式调用
+func (b *StoreBuilder[T]) OnError(fn func(error)) *StoreBuilder[T] {
+	b.onError = fn
+	return b
+}
+
 // AddIndex 添加字段索引配置。
 // 参数:
 //   - field: 要索引的字段名
@@ -68,22 +248,83 @@ func (b *StoreBuilder[T]) AddIndex(field string, extractor func(*types.Record[T]
 //   - *storage.Store[T]: 构建的存储实例
 //   - error: 构建过程中的错误
 func (b *StoreBuilder[T]) Build() (*storage.Store[T], error) {
+	opts, err := b.options()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := storage.Open[T](opts)
+	if err != nil {
+		return nil, err
+	}
+
+	b.built = true
+	return store, nil
+}
+
+// BuildFromSnapshot 从快照构建存储实例，字段索引按构建器中的配置重建。
+// 参数:
+//   - r: 由 Store.Snapshot 写出的快照数据
+//
+// 返回:
+//   - *storage.Store[T]: 恢复后的存储实例
+//   - error: 构建或读取快照过程中的错误
+func (b *StoreBuilder[T]) BuildFromSnapshot(r io.Reader) (*storage.Store[T], error) {
+	opts, err := b.options()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := storage.Restore[T](r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	b.built = true
+	return store, nil
+}
+
+// options 校验构建器状态并生成存储配置
+func (b *StoreBuilder[T]) options() (storage.Options, error) {
 	if b.built {
-		return nil, fmt.Errorf("store builder already used")
+		return storage.Options{}, fmt.Errorf("store builder already used")
 	}
 
 	if b.initialCapacity <= 0 {
-		return nil, fmt.Errorf("initial capacity must be positive")
+		return storage.Options{}, fmt.Errorf("initial capacity must be positive")
 	}
 
-	opts := storage.Options{
-		InitialCapacity:  b.initialCapacity,
-		EnableVersioning: b.enableVersioning,
-		FieldIndexes:     b.indexBuilder.Build(),
+	if b.historyLimit > 0 && !b.enableVersioning {
+		return storage.Options{}, fmt.Errorf("version history requires versioning")
 	}
 
-	b.built = true
-	return storage.New[T](opts), nil
+	if b.eviction == storage.EvictLowestPriority && b.priority == nil {
+		return storage.Options{}, fmt.Errorf("lowest-priority eviction requires a priority function")
+	}
+
+	return storage.Options{
+		InitialCapacity:    b.initialCapacity,
+		EnableVersioning:   b.enableVersioning,
+		HistoryLimit:       b.historyLimit,
+		FieldIndexes:       b.indexBuilder.Build(),
+		SafeReads:          b.safeReads,
+		PrimaryKey:         b.primaryKey,
+		WALPath:            b.walPath,
+		SyncWrites:         b.syncWrites,
+		CompactLogSize:     b.compactLogSize,
+		CompactInterval:    b.compactInterval,
+		TombstoneRatio:     b.tombstoneRatio,
+		TombstoneRetention: b.tombstoneKeep,
+		DefaultTTL:         b.defaultTTL,
+		ReapInterval:       b.reapInterval,
+		MaxRecords:         b.maxRecords,
+		MaxBytes:           b.maxBytes,
+		Eviction:           b.eviction,
+		RecordSize:         b.recordSize,
+		Priority:           b.priority,
+		OnEvict:            b.onEvict,
+		OnError:            b.onError,
+	}, nil
 }
 
 // IndexBuilder 是一个用于构建字段索引配置的构建器。
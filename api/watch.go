@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ldChengYi/EasyDB/core/storage"
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// Watch 订阅满足查询条件的记录变更，用来代替定时轮询 Do。
+// 变更前或变更后的状态满足 Where、Or、Not、And 和时间范围条件时发送事件，
+// 因此记录被修改到不再满足条件、或被删除时也能收到；Limit、Offset、OrderBy、InView、AsOf 不生效。
+// 条件在 Store 的写锁内逐条校验，应尽量简单。
+// 参数:
+//   - ctx: 上下文，取消时关闭通道
+//   - opts: 排队上限和处理不过来时的策略（排队、丢弃或断开）
+//
+// 返回:
+//   - <-chan storage.ChangeEvent[T]: 按提交顺序排列的变更事件
+//   - error: 条件引用了未注册的字段等错误
+func (q *Query[T]) Watch(ctx context.Context, opts storage.WatchOptions) (<-chan storage.ChangeEvent[T], error) {
+	for _, node := range q.nodes {
+		if err := q.validateNode(node); err != nil {
+			return nil, err
+		}
+	}
+
+	var filter func(*types.Record[T]) bool
+	if len(q.nodes) > 0 || q.timeRange.enabled {
+		filter = func(r *types.Record[T]) bool {
+			ok, err := q.matchAll(r)
+			return err == nil && ok
+		}
+	}
+	return q.store.Watch(ctx, filter, opts), nil
+}
+
+// validateNode 检查查询树中的条件能否逐条校验，避免订阅后所有事件都因错误被过滤掉。
+// 参数:
+//   - node: 查询树节点
+//
+// 返回:
+//   - error: 字段未注册或比较值无法转换时的错误
+func (q *Query[T]) validateNode(node *queryNode) error {
+	switch node.kind {
+	case nodeCondition:
+		if _, ok := q.store.IndexManager.GetExtractor(node.cond.field); !ok {
+			return fmt.Errorf("field extractor not found for field: %s", node.cond.field)
+		}
+		switch node.cond.operator {
+		case opEquals:
+			if _, err := q.equalKey(node.cond); err != nil {
+				return err
+			}
+		case opIn:
+			if err := q.checkEqualityIndex(node.cond.field); err != nil {
+				return err
+			}
+		case opBetween, opGt, opGte, opLt, opLte:
+			if _, _, _, _, err := q.rangeKeys(node.cond); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nodeAnd, nodeOr, nodeNot:
+		for _, child := range node.children {
+			if err := q.validateNode(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported query node: %d", node.kind)
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/storage"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchReportsLastRecordLeavingFilter(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStoreBuilder[person]().
+		AddIndex("Name", func(r *types.Record[person]) interface{} { return r.Data.Name }, storage.IndexExact).
+		Build()
+	require.NoError(t, err)
+	defer store.Close()
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := NewQuery(store).Where("Name").Equals("TCP").Watch(watchCtx, storage.WatchOptions{})
+	require.NoError(t, err)
+
+	first, err := store.Insert(ctx, person{Name: "TCP"})
+	require.NoError(t, err)
+	_, err = store.Insert(ctx, person{Name: "UDP"})
+	require.NoError(t, err)
+	// 唯一的 TCP 记录被改为 UDP 后，索引中已没有 TCP，仍要收到离开过滤范围的事件
+	_, err = store.Update(ctx, first.ID, person{Name: "UDP"})
+	require.NoError(t, err)
+	second, err := store.Insert(ctx, person{Name: "TCP"})
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(ctx, second.ID))
+
+	want := []storage.ChangeOp{storage.ChangeInsert, storage.ChangeUpdate, storage.ChangeInsert, storage.ChangeDelete}
+	for i, op := range want {
+		select {
+		case ev := <-events:
+			assert.Equal(t, op, ev.Op, "event %d", i)
+			if op == storage.ChangeUpdate {
+				assert.Equal(t, "TCP", ev.Old.Data.Name)
+				assert.Equal(t, "UDP", ev.New.Data.Name)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing event %d (%s)", i, op)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %s for %s", ev.Op, ev.New.Data.Name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,137 @@
+package ds
+
+import (
+	"sort"
+	"strings"
+)
+
+// NGramIndex 是按字符（rune）切分的 n-gram 倒排索引，用于子串匹配。
+// 每个位置只索引长度 1..n 的 gram，内存与值长度成线性关系；
+// 长于 n 的查询先求各 n-gram 倒排表的交集，再用原始值校验候选。
+type NGramIndex struct {
+	n        int
+	postings map[string]map[uint64]struct{} // gram -> ID 集合
+	values   map[uint64]string              // ID -> 原始值，用于校验候选
+}
+
+// NewNGramIndex 创建 gram 长度为 n 的索引，n <= 0 时使用 3
+func NewNGramIndex(n int) *NGramIndex {
+	if n <= 0 {
+		n = 3
+	}
+	return &NGramIndex{
+		n:        n,
+		postings: make(map[string]map[uint64]struct{}),
+		values:   make(map[uint64]string),
+	}
+}
+
+// forEachGram 遍历值中所有长度为 1..n 的 gram（可能重复）
+func (g *NGramIndex) forEachGram(value string, fn func(gram string)) {
+	runes := []rune(value)
+	for i := range runes {
+		for l := 1; l <= g.n && i+l <= len(runes); l++ {
+			fn(string(runes[i : i+l]))
+		}
+	}
+}
+
+// Insert 将 value 绑定到 id 上
+func (g *NGramIndex) Insert(value string, id uint64) {
+	// 保存按 rune 规范化后的值（无效的 UTF-8 字节变为 U+FFFD），校验候选时与 gram 的切分方式一致
+	g.values[id] = string([]rune(value))
+	g.forEachGram(value, func(gram string) {
+		set, ok := g.postings[gram]
+		if !ok {
+			set = make(map[uint64]struct{})
+			g.postings[gram] = set
+		}
+		set[id] = struct{}{}
+	})
+}
+
+// Delete 移除 id 对应的值
+func (g *NGramIndex) Delete(value string, id uint64) {
+	if _, ok := g.values[id]; !ok {
+		return
+	}
+	delete(g.values, id)
+	g.forEachGram(value, func(gram string) {
+		if set, ok := g.postings[gram]; ok {
+			delete(set, id)
+			if len(set) == 0 {
+				delete(g.postings, gram)
+			}
+		}
+	})
+}
+
+// Query 返回值中包含 substr 的所有 ID，结果为新分配的集合
+func (g *NGramIndex) Query(substr string) map[uint64]struct{} {
+	result := make(map[uint64]struct{})
+	runes := []rune(substr)
+	substr = string(runes) // 与建立索引时一样按 rune 规范化
+
+	// 空串匹配所有值
+	if len(runes) == 0 {
+		for id := range g.values {
+			result[id] = struct{}{}
+		}
+		return result
+	}
+
+	// 不长于 n 的 gram 已被完整索引，直接返回倒排表
+	if len(runes) <= g.n {
+		for id := range g.postings[substr] {
+			result[id] = struct{}{}
+		}
+		return result
+	}
+
+	// 收集所有 n-gram 的倒排表，任意一个缺失即无结果
+	lists := make([]map[uint64]struct{}, 0, len(runes)-g.n+1)
+	for i := 0; i+g.n <= len(runes); i++ {
+		set, ok := g.postings[string(runes[i:i+g.n])]
+		if !ok {
+			return result
+		}
+		lists = append(lists, set)
+	}
+
+	// 从最短的倒排表开始求交集，再校验候选
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	for id := range lists[0] {
+		matched := true
+		for _, set := range lists[1:] {
+			if _, ok := set[id]; !ok {
+				matched = false
+				break
+			}
+		}
+		if matched && strings.Contains(g.values[id], substr) {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
+
+// Estimate 返回查询 substr 候选数量的上界（最短倒排表的长度），不做校验
+func (g *NGramIndex) Estimate(substr string) int {
+	runes := []rune(substr)
+	substr = string(runes)
+	if len(runes) == 0 {
+		return len(g.values)
+	}
+	if len(runes) <= g.n {
+		return len(g.postings[substr])
+	}
+
+	min := -1
+	for i := 0; i+g.n <= len(runes); i++ {
+		size := len(g.postings[string(runes[i:i+g.n])])
+		if min < 0 || size < min {
+			min = size
+		}
+	}
+	return min
+}
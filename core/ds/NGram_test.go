@@ -0,0 +1,119 @@
+package ds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNGramInvalidUTF8(t *testing.T) {
+	g := NewNGramIndex(3)
+	g.Insert("a\xffbcdef", 1)
+	g.Insert("abc", 2)
+
+	for _, substr := range []string{"\xff", "a\xffb", "\xffbcd", "a\xffbcdef"} {
+		assert.Equal(t, map[uint64]struct{}{1: {}}, g.Query(substr), "query %q", substr)
+		assert.Equal(t, 1, g.Estimate(substr), "estimate %q", substr)
+	}
+}
+
+func TestNGramQuery(t *testing.T) {
+	g := NewNGramIndex(3)
+	values := map[uint64]string{
+		1: "数据库引擎",
+		2: "内存数据库",
+		3: "https://example.com/path",
+		4: "abcabc",
+		5: "",
+	}
+	for id, v := range values {
+		g.Insert(v, id)
+	}
+
+	ids := func(list ...uint64) map[uint64]struct{} {
+		set := make(map[uint64]struct{}, len(list))
+		for _, id := range list {
+			set[id] = struct{}{}
+		}
+		return set
+	}
+
+	tests := []struct {
+		name   string
+		substr string
+		want   map[uint64]struct{}
+	}{
+		{name: "empty matches all", substr: "", want: ids(1, 2, 3, 4, 5)},
+		{name: "single rune", substr: "库", want: ids(1, 2)},
+		{name: "two runes shorter than n", substr: "数据", want: ids(1, 2)},
+		{name: "exactly n runes", substr: "数据库", want: ids(1, 2)},
+		{name: "longer than n", substr: "数据库引擎", want: ids(1)},
+		{name: "multi-byte mid value", substr: "存数据", want: ids(2)},
+		{name: "not split mid rune", substr: "\xe6\x95", want: ids()},
+		{name: "grams present but not adjacent", substr: "引擎数据库", want: ids()},
+		{name: "ascii long", substr: "example.com", want: ids(3)},
+		{name: "repeated grams", substr: "cabc", want: ids(4)},
+		{name: "absent short", substr: "xyz", want: ids()},
+		{name: "absent long", substr: "abcabcabc", want: ids()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, g.Query(tt.substr))
+			assert.GreaterOrEqual(t, g.Estimate(tt.substr), len(tt.want))
+		})
+	}
+}
+
+func TestNGramDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		insert  map[uint64]string
+		remove  map[uint64]string
+		queries map[string][]uint64
+	}{
+		{
+			name:    "remove one of two sharing grams",
+			insert:  map[uint64]string{1: "数据库", 2: "数据"},
+			remove:  map[uint64]string{1: "数据库"},
+			queries: map[string][]uint64{"数据": {2}, "库": nil, "数据库": nil},
+		},
+		{
+			name:    "remove absent id",
+			insert:  map[uint64]string{1: "abcd"},
+			remove:  map[uint64]string{2: "abcd"},
+			queries: map[string][]uint64{"abcd": {1}, "bc": {1}},
+		},
+		{
+			name:    "remove long value",
+			insert:  map[uint64]string{1: "hello world", 2: "world"},
+			remove:  map[uint64]string{1: "hello world"},
+			queries: map[string][]uint64{"hello": nil, "world": {2}, "o w": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewNGramIndex(3)
+			for id, v := range tt.insert {
+				g.Insert(v, id)
+			}
+			for id, v := range tt.remove {
+				g.Delete(v, id)
+			}
+			for substr, want := range tt.queries {
+				got := g.Query(substr)
+				assert.Len(t, got, len(want), "query %q", substr)
+				for _, id := range want {
+					assert.Contains(t, got, id, "query %q", substr)
+				}
+			}
+		})
+	}
+
+	// 全部删除后不留下空的倒排表
+	g := NewNGramIndex(2)
+	g.Insert("ab", 1)
+	g.Delete("ab", 1)
+	assert.Empty(t, g.postings)
+	assert.Empty(t, g.values)
+}
@@ -0,0 +1,137 @@
+package ds
+
+import "math/bits"
+
+// RankSet 是非负整数位置的集合，由位图和按 64 位字统计的树状数组组成：
+// 添加、删除和按排名定位（Select）都是 O(log n)，顺序遍历按字跳过空白区域。
+type RankSet struct {
+	words []uint64 // 位图
+	tree  []int    // 树状数组（下标从 1 开始），统计各字中置位的数量
+	count int
+}
+
+// NewRankSet 创建可容纳 capacity 个位置的集合，超出时自动扩容
+func NewRankSet(capacity int) *RankSet {
+	r := &RankSet{}
+	r.grow((capacity + 63) / 64)
+	return r
+}
+
+// grow 将位图扩展到至少 n 个字，并重建树状数组
+func (r *RankSet) grow(n int) {
+	if n <= len(r.words) {
+		return
+	}
+	if n < 2*len(r.words) {
+		n = 2 * len(r.words)
+	}
+
+	words := make([]uint64, n)
+	copy(words, r.words)
+	r.words = words
+
+	r.tree = make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		r.tree[i] += bits.OnesCount64(r.words[i-1])
+		if j := i + i&-i; j <= n {
+			r.tree[j] += r.tree[i]
+		}
+	}
+}
+
+// update 将第 w 个字的计数加上 delta
+func (r *RankSet) update(w, delta int) {
+	for i := w + 1; i < len(r.tree); i += i & -i {
+		r.tree[i] += delta
+	}
+}
+
+// Add 加入位置 pos，已存在时忽略
+func (r *RankSet) Add(pos int) {
+	w, bit := pos/64, uint64(1)<<(pos%64)
+	r.grow(w + 1)
+	if r.words[w]&bit != 0 {
+		return
+	}
+	r.words[w] |= bit
+	r.update(w, 1)
+	r.count++
+}
+
+// Remove 移除位置 pos，不存在时忽略
+func (r *RankSet) Remove(pos int) {
+	w, bit := pos/64, uint64(1)<<(pos%64)
+	if w >= len(r.words) || r.words[w]&bit == 0 {
+		return
+	}
+	r.words[w] &^= bit
+	r.update(w, -1)
+	r.count--
+}
+
+// Contains 判断位置 pos 是否在集合中
+func (r *RankSet) Contains(pos int) bool {
+	w := pos / 64
+	return w < len(r.words) && r.words[w]&(uint64(1)<<(pos%64)) != 0
+}
+
+// Len 返回集合中的位置数
+func (r *RankSet) Len() int {
+	return r.count
+}
+
+// Select 返回按升序排第 k 个（从 0 开始）的位置，k 越界时返回 -1
+func (r *RankSet) Select(k int) int {
+	if k < 0 || k >= r.count {
+		return -1
+	}
+
+	// 在树状数组上二分，找到第 k 个位置所在的字
+	n := len(r.words)
+	w := 0
+	for step := 1 << (bits.Len(uint(n)) - 1); step > 0; step >>= 1 {
+		if next := w + step; next <= n && r.tree[next] <= k {
+			w = next
+			k -= r.tree[next]
+		}
+	}
+
+	word := r.words[w]
+	for ; k > 0; k-- {
+		word &= word - 1
+	}
+	return w*64 + bits.TrailingZeros64(word)
+}
+
+// Next 返回不小于 pos 的最小位置，不存在时返回 -1
+func (r *RankSet) Next(pos int) int {
+	if pos < 0 {
+		pos = 0
+	}
+	w := pos / 64
+	if w >= len(r.words) {
+		return -1
+	}
+
+	word := r.words[w] &^ (uint64(1)<<(pos%64) - 1)
+	for word == 0 {
+		w++
+		if w >= len(r.words) {
+			return -1
+		}
+		word = r.words[w]
+	}
+	return w*64 + bits.TrailingZeros64(word)
+}
+
+// Range 按升序遍历集合中的位置，fn 返回 false 时停止
+func (r *RankSet) Range(fn func(pos int) bool) {
+	for w, word := range r.words {
+		for word != 0 {
+			if !fn(w*64 + bits.TrailingZeros64(word)) {
+				return
+			}
+			word &= word - 1
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankSetSelect(t *testing.T) {
+	tests := []struct {
+		name    string
+		add     []int
+		remove  []int
+		want    []int // 按升序排列的位置，第 k 个即 Select(k)
+		missing []int
+	}{
+		{name: "empty", want: nil, missing: []int{0, 63, 64}},
+		{name: "word boundaries", add: []int{0, 63, 64, 127, 128}, want: []int{0, 63, 64, 127, 128}},
+		{name: "grows past capacity", add: []int{5, 1000, 4095}, want: []int{5, 1000, 4095}},
+		{name: "duplicate add", add: []int{3, 3, 3}, want: []int{3}},
+		{name: "remove first and last", add: []int{0, 10, 64, 200}, remove: []int{0, 200}, want: []int{10, 64}, missing: []int{0, 200}},
+		{name: "remove absent", add: []int{1}, remove: []int{2, 5000}, want: []int{1}},
+		{name: "clear whole word", add: []int{64, 65, 127, 130}, remove: []int{64, 65, 127}, want: []int{130}, missing: []int{64, 65, 127}},
+		{name: "remove all", add: []int{7, 8}, remove: []int{7, 8}, want: nil, missing: []int{7, 8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRankSet(64)
+			for _, pos := range tt.add {
+				r.Add(pos)
+			}
+			for _, pos := range tt.remove {
+				r.Remove(pos)
+			}
+
+			assert.Equal(t, len(tt.want), r.Len())
+			for k, pos := range tt.want {
+				assert.Equal(t, pos, r.Select(k), "Select(%d)", k)
+				assert.True(t, r.Contains(pos))
+			}
+			assert.Equal(t, -1, r.Select(-1))
+			assert.Equal(t, -1, r.Select(len(tt.want)))
+			for _, pos := range tt.missing {
+				assert.False(t, r.Contains(pos))
+			}
+
+			var ranged []int
+			r.Range(func(pos int) bool {
+				ranged = append(ranged, pos)
+				return true
+			})
+			assert.Equal(t, tt.want, ranged)
+		})
+	}
+}
+
+func TestRankSetNext(t *testing.T) {
+	r := NewRankSet(0)
+	for _, pos := range []int{0, 63, 64, 300} {
+		r.Add(pos)
+	}
+	r.Remove(64)
+
+	tests := []struct {
+		pos  int
+		want int
+	}{
+		{pos: -5, want: 0},
+		{pos: 0, want: 0},
+		{pos: 1, want: 63},
+		{pos: 63, want: 63},
+		{pos: 64, want: 300},
+		{pos: 300, want: 300},
+		{pos: 301, want: -1},
+		{pos: 100000, want: -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, r.Next(tt.pos), "Next(%d)", tt.pos)
+	}
+
+	// 清空后重新加入，计数和排名随之更新
+	for _, pos := range []int{0, 63, 300} {
+		r.Remove(pos)
+	}
+	assert.Equal(t, 0, r.Len())
+	assert.Equal(t, -1, r.Next(0))
+	r.Add(63)
+	assert.Equal(t, 1, r.Len())
+	assert.Equal(t, 63, r.Select(0))
+	assert.Equal(t, 63, r.Next(0))
+}
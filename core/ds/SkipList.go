@@ -0,0 +1,157 @@
+package ds
+
+import "math/rand"
+
+// skipListMaxLevel 跳表最大层数，足以支撑上亿个不同的键
+const skipListMaxLevel = 32
+
+// skipNode 表示跳表中的一个节点，同一个键的所有 ID 聚合在一个节点上
+type skipNode struct {
+	key  interface{}
+	ids  map[uint64]struct{}
+	next []*skipNode
+}
+
+// SkipList 是按键有序的跳表，用于范围查询（键 -> ID 集合）
+type SkipList struct {
+	head    *skipNode
+	level   int
+	length  int // 不同键的数量
+	count   int // ID 总数
+	compare func(a, b interface{}) int
+}
+
+// NewSkipList 使用给定的比较函数创建跳表
+func NewSkipList(compare func(a, b interface{}) int) *SkipList {
+	return &SkipList{
+		head:    &skipNode{next: make([]*skipNode, skipListMaxLevel)},
+		level:   1,
+		compare: compare,
+	}
+}
+
+// randomLevel 以 1/4 的概率逐层晋升
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Int63()&3 == 0 {
+		level++
+	}
+	return level
+}
+
+// findPrev 返回每一层中最后一个键小于 key 的节点
+func (sl *SkipList) findPrev(key interface{}) []*skipNode {
+	prev := make([]*skipNode, skipListMaxLevel)
+	node := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && sl.compare(node.next[i].key, key) < 0 {
+			node = node.next[i]
+		}
+		prev[i] = node
+	}
+	return prev
+}
+
+// Insert 将 id 绑定到 key 上
+func (sl *SkipList) Insert(key interface{}, id uint64) {
+	prev := sl.findPrev(key)
+	if n := prev[0].next[0]; n != nil && sl.compare(n.key, key) == 0 {
+		if _, ok := n.ids[id]; !ok {
+			n.ids[id] = struct{}{}
+			sl.count++
+		}
+		return
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			prev[i] = sl.head
+		}
+		sl.level = level
+	}
+
+	node := &skipNode{
+		key:  key,
+		ids:  map[uint64]struct{}{id: {}},
+		next: make([]*skipNode, level),
+	}
+	for i := 0; i < level; i++ {
+		node.next[i] = prev[i].next[i]
+		prev[i].next[i] = node
+	}
+	sl.length++
+	sl.count++
+}
+
+// Delete 解除 id 与 key 的绑定，键上没有 ID 时移除节点
+func (sl *SkipList) Delete(key interface{}, id uint64) {
+	prev := sl.findPrev(key)
+	node := prev[0].next[0]
+	if node == nil || sl.compare(node.key, key) != 0 {
+		return
+	}
+	if _, ok := node.ids[id]; !ok {
+		return
+	}
+
+	delete(node.ids, id)
+	sl.count--
+	if len(node.ids) > 0 {
+		return
+	}
+
+	for i := 0; i < len(node.next); i++ {
+		if prev[i].next[i] == node {
+			prev[i].next[i] = node.next[i]
+		}
+	}
+	for sl.level > 1 && sl.head.next[sl.level-1] == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+// Range 按键升序遍历 [lo, hi] 区间内的节点，fn 返回 false 时停止。
+// lo 或 hi 为 nil 表示该侧无界，includeLo/includeHi 控制是否包含边界。
+func (sl *SkipList) Range(lo, hi interface{}, includeLo, includeHi bool, fn func(key interface{}, ids map[uint64]struct{}) bool) {
+	var node *skipNode
+	if lo == nil {
+		node = sl.head.next[0]
+	} else {
+		node = sl.findPrev(lo)[0].next[0]
+		if !includeLo && node != nil && sl.compare(node.key, lo) == 0 {
+			node = node.next[0]
+		}
+	}
+
+	for ; node != nil; node = node.next[0] {
+		if hi != nil {
+			c := sl.compare(node.key, hi)
+			if c > 0 || (c == 0 && !includeHi) {
+				return
+			}
+		}
+		if !fn(node.key, node.ids) {
+			return
+		}
+	}
+}
+
+// First 返回最小的键，跳表为空时第二个返回值为 false
+func (sl *SkipList) First() (interface{}, bool) {
+	if node := sl.head.next[0]; node != nil {
+		return node.key, true
+	}
+	return nil, false
+}
+
+// Len 返回不同键的数量
+func (sl *SkipList) Len() int {
+	return sl.length
+}
+
+// Count 返回 ID 总数
+func (sl *SkipList) Count() int {
+	return sl.count
+}
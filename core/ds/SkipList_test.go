@@ -0,0 +1,167 @@
+package ds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compareInts(a, b interface{}) int {
+	return a.(int) - b.(int)
+}
+
+// collectRange 返回范围内按顺序排列的键，以及所有 ID
+func collectRange(sl *SkipList, lo, hi interface{}, includeLo, includeHi bool) ([]int, map[uint64]struct{}) {
+	var keys []int
+	ids := make(map[uint64]struct{})
+	sl.Range(lo, hi, includeLo, includeHi, func(key interface{}, set map[uint64]struct{}) bool {
+		keys = append(keys, key.(int))
+		for id := range set {
+			ids[id] = struct{}{}
+		}
+		return true
+	})
+	return keys, ids
+}
+
+func TestSkipListRange(t *testing.T) {
+	sl := NewSkipList(compareInts)
+	for i, key := range []int{50, 10, 30, 20, 40} {
+		sl.Insert(key, uint64(i+1))
+	}
+
+	tests := []struct {
+		name      string
+		lo, hi    interface{}
+		includeLo bool
+		includeHi bool
+		want      []int
+	}{
+		{name: "inclusive", lo: 20, hi: 40, includeLo: true, includeHi: true, want: []int{20, 30, 40}},
+		{name: "exclusive", lo: 20, hi: 40, want: []int{30}},
+		{name: "exclusive low", lo: 20, hi: 40, includeHi: true, want: []int{30, 40}},
+		{name: "exclusive high", lo: 20, hi: 40, includeLo: true, want: []int{20, 30}},
+		{name: "bounds between keys", lo: 15, hi: 35, want: []int{20, 30}},
+		{name: "unbounded low", hi: 30, includeHi: true, want: []int{10, 20, 30}},
+		{name: "unbounded high", lo: 30, want: []int{40, 50}},
+		{name: "unbounded", want: []int{10, 20, 30, 40, 50}},
+		{name: "empty exclusive point", lo: 30, hi: 30, includeLo: true, want: nil},
+		{name: "inclusive point", lo: 30, hi: 30, includeLo: true, includeHi: true, want: []int{30}},
+		{name: "below all keys", hi: 10, want: nil},
+		{name: "above all keys", lo: 50, want: nil},
+		{name: "inverted bounds", lo: 40, hi: 20, includeLo: true, includeHi: true, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, _ := collectRange(sl, tt.lo, tt.hi, tt.includeLo, tt.includeHi)
+			assert.Equal(t, tt.want, keys)
+		})
+	}
+
+	// fn 返回 false 时停止
+	var visited int
+	sl.Range(nil, nil, true, true, func(interface{}, map[uint64]struct{}) bool {
+		visited++
+		return visited < 2
+	})
+	assert.Equal(t, 2, visited)
+}
+
+func TestSkipListDuplicatesAndDelete(t *testing.T) {
+	tests := []struct {
+		name      string
+		inserts   map[int][]uint64 // 键 -> 依次绑定的 ID
+		deletes   map[int][]uint64
+		wantKeys  []int
+		wantCount int
+		wantFirst interface{}
+	}{
+		{
+			name:      "duplicate keys share a node",
+			inserts:   map[int][]uint64{1: {1, 2, 3}, 2: {4}},
+			wantKeys:  []int{1, 2},
+			wantCount: 4,
+			wantFirst: 1,
+		},
+		{
+			name:      "same id twice",
+			inserts:   map[int][]uint64{1: {1, 1}},
+			wantKeys:  []int{1},
+			wantCount: 1,
+			wantFirst: 1,
+		},
+		{
+			name:      "delete one of several ids keeps the key",
+			inserts:   map[int][]uint64{1: {1, 2}, 2: {3}},
+			deletes:   map[int][]uint64{1: {1}},
+			wantKeys:  []int{1, 2},
+			wantCount: 2,
+			wantFirst: 1,
+		},
+		{
+			name:      "delete last id removes the key",
+			inserts:   map[int][]uint64{1: {1}, 2: {2}},
+			deletes:   map[int][]uint64{1: {1}},
+			wantKeys:  []int{2},
+			wantCount: 1,
+			wantFirst: 2,
+		},
+		{
+			name:      "delete absent key or id",
+			inserts:   map[int][]uint64{1: {1}},
+			deletes:   map[int][]uint64{1: {9}, 5: {1}},
+			wantKeys:  []int{1},
+			wantCount: 1,
+			wantFirst: 1,
+		},
+		{
+			name:      "delete everything",
+			inserts:   map[int][]uint64{1: {1}, 2: {2, 3}},
+			deletes:   map[int][]uint64{1: {1}, 2: {2, 3}},
+			wantKeys:  nil,
+			wantCount: 0,
+			wantFirst: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sl := NewSkipList(compareInts)
+			for key, ids := range tt.inserts {
+				for _, id := range ids {
+					sl.Insert(key, id)
+				}
+			}
+			for key, ids := range tt.deletes {
+				for _, id := range ids {
+					sl.Delete(key, id)
+				}
+			}
+
+			keys, ids := collectRange(sl, nil, nil, true, true)
+			assert.Equal(t, tt.wantKeys, keys)
+			assert.Len(t, ids, tt.wantCount)
+			assert.Equal(t, len(tt.wantKeys), sl.Len())
+			assert.Equal(t, tt.wantCount, sl.Count())
+
+			first, ok := sl.First()
+			assert.Equal(t, tt.wantFirst != nil, ok)
+			assert.Equal(t, tt.wantFirst, first)
+		})
+	}
+}
+
+func TestSkipListManyKeys(t *testing.T) {
+	sl := NewSkipList(compareInts)
+	for i := 0; i < 1000; i++ {
+		sl.Insert(i, uint64(i))
+	}
+	for i := 0; i < 1000; i += 2 {
+		sl.Delete(i, uint64(i))
+	}
+
+	keys, _ := collectRange(sl, 100, 110, true, false)
+	assert.Equal(t, []int{101, 103, 105, 107, 109}, keys)
+	assert.Equal(t, 500, sl.Len())
+}
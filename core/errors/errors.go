@@ -32,4 +32,13 @@ var (
 
 	// ErrNoSnapshot 快照不存在
 	ErrNoSnapshot = errors.New("快照不存在")
+
+	// ErrSnapshotCorrupted 快照文件损坏或格式不支持
+	ErrSnapshotCorrupted = errors.New("快照文件损坏")
+
+	// ErrTxDone 事务已提交或回滚
+	ErrTxDone = errors.New("事务已提交或回滚")
+
+	// ErrVersionNotFound 请求的版本不在保留的历史中
+	ErrVersionNotFound = errors.New("版本不在历史记录中")
 )
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// BatchResult 批量操作中单条数据的结果
+type BatchResult[T any] struct {
+	Record *types.Record[T] // 操作后的记录，失败或未生效时为 nil
+	Err    error            // 该条数据失败的原因
+}
+
+// BatchUpdate 批量更新中的一条数据
+type BatchUpdate[T any] struct {
+	ID   uint64
+	Data T
+}
+
+// batch 在一次写锁内暂存批量操作：按输入顺序逐条校验（能看到前面已接受的数据）后缓存，
+// 最后作为一帧写入日志并整体生效。
+// allOrNothing 为 true 时任意一条失败都会放弃整个批次，否则失败的数据被跳过。
+type batch[T any] struct {
+	store        *Store[T]
+	allOrNothing bool
+	ops          []walEntry[T]               // 通过校验的操作
+	items        []int                       // ops[k] 对应的输入下标
+	writes       map[uint64]*types.Record[T] // 批次内每条记录的最新状态
+	unique       *uniqueBatch[T]
+	inserts      int
+	results      []BatchResult[T]
+}
+
+// newBatch 创建容纳 n 条数据的批次（调用方持有写锁）
+func (s *Store[T]) newBatch(n int, allOrNothing bool) *batch[T] {
+	return &batch[T]{
+		store:        s,
+		allOrNothing: allOrNothing,
+		ops:          make([]walEntry[T], 0, n),
+		items:        make([]int, 0, n),
+		writes:       make(map[uint64]*types.Record[T], n),
+		unique:       s.IndexManager.newUniqueBatch(),
+		results:      make([]BatchResult[T], n),
+	}
+}
+
+// current 返回记录在批次视角下的最新状态（可能已删除），不存在时返回 nil
+func (b *batch[T]) current(id uint64) *types.Record[T] {
+	if record, ok := b.writes[id]; ok {
+		return record
+	}
+	return b.store.recordLocked(id)
+}
+
+// reject 记录第 i 条数据失败，仅在需要放弃整个批次时返回错误
+func (b *batch[T]) reject(i int, err error) error {
+	b.results[i].Err = err
+	if b.allOrNothing {
+		return fmt.Errorf("batch item %d: %w", i, err)
+	}
+	return nil
+}
+
+// stage 校验唯一约束并暂存第 i 条数据把记录从 prev 变为 next 的操作，
+// 仅在需要放弃整个批次时返回错误
+func (b *batch[T]) stage(i int, op walOp, prev, next *types.Record[T]) error {
+	if err := b.unique.check(next.ID, next); err != nil {
+		return b.reject(i, err)
+	}
+	b.unique.accept(next.ID, prev, next)
+
+	b.ops = append(b.ops, walEntry[T]{Op: op, Record: *next})
+	b.items = append(b.items, i)
+	b.writes[next.ID] = next
+	if op == walOpInsert {
+		b.inserts++
+	}
+	return nil
+}
+
+// commit 将暂存的操作作为一帧写入日志后依次应用，并填充每条数据的结果
+func (b *batch[T]) commit() ([]BatchResult[T], error) {
+	if len(b.ops) == 0 {
+		return b.results, nil
+	}
+
+	s := b.store
+	if err := s.appendWALBatch(b.ops); err != nil {
+		for _, i := range b.items {
+			b.results[i].Err = err
+		}
+		return b.results, err
+	}
+
+	s.data = slices.Grow(s.data, b.inserts)
+	for k := range b.ops {
+		if err := s.applyEntry(&b.ops[k]); err != nil {
+			// 校验已保证不会发生，出现即为内部状态错误
+			panic(fmt.Sprintf("storage: apply batch: %v", err))
+		}
+		b.results[b.items[k]].Record = s.export(s.data[s.idMapIndex[b.ops[k].Record.ID]])
+	}
+	return b.results, nil
+}
+
+// InsertMany 在一次写锁内插入多条记录，结果与 items 一一对应。
+// allOrNothing 为 true 时任意一条失败则全部不生效并返回错误；
+// 否则跳过失败的数据，失败原因记录在对应结果的 Err 中。
+func (s *Store[T]) InsertMany(ctx context.Context, items []T, allOrNothing bool) ([]BatchResult[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.reapForUniqueLocked(); err != nil {
+		return nil, err
+	}
+
+	b := s.newBatch(len(items), allOrNothing)
+	for i, data := range items {
+		if err := b.stage(i, walOpInsert, nil, s.newRecord(data, s.options.DefaultTTL)); err != nil {
+			return b.results, err
+		}
+	}
+	defer s.enforceLimitsLocked(b.writes)
+	return b.commit()
+}
+
+// UpdateMany 在一次写锁内更新多条记录，同一 ID 出现多次时按顺序依次生效。
+// allOrNothing 的含义与 InsertMany 相同。
+func (s *Store[T]) UpdateMany(ctx context.Context, items []BatchUpdate[T], allOrNothing bool) ([]BatchResult[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.reapForUniqueLocked(); err != nil {
+		return nil, err
+	}
+
+	b := s.newBatch(len(items), allOrNothing)
+	for i, item := range items {
+		prev := b.current(item.ID)
+		if err := liveErr(prev, time.Now().UnixNano()); err != nil {
+			if err := b.reject(i, err); err != nil {
+				return b.results, err
+			}
+			continue
+		}
+
+		next := *prev
+		next.Data = s.importData(item.Data)
+		next.Meta.UpdatedAt = time.Now().UnixNano()
+		if s.options.EnableVersioning {
+			next.Version++
+		}
+		if err := b.stage(i, walOpUpdate, prev, &next); err != nil {
+			return b.results, err
+		}
+	}
+	defer s.enforceLimitsLocked(b.writes)
+	return b.commit()
+}
+
+// DeleteMany 在一次写锁内删除多条记录，结果中的 Record 为删除后的记录状态。
+// allOrNothing 的含义与 InsertMany 相同。
+func (s *Store[T]) DeleteMany(ctx context.Context, ids []uint64, allOrNothing bool) ([]BatchResult[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	b := s.newBatch(len(ids), allOrNothing)
+	for i, id := range ids {
+		if err := b.stageDelete(i, id); err != nil {
+			return b.results, err
+		}
+	}
+	return b.commit()
+}
+
+// stageDelete 暂存第 i 条数据对记录 id 的删除，仅在需要放弃整个批次时返回错误
+func (b *batch[T]) stageDelete(i int, id uint64) error {
+	prev := b.current(id)
+	if err := liveErr(prev, time.Now().UnixNano()); err != nil {
+		return b.reject(i, err)
+	}
+	return b.stageTombstone(i, prev)
+}
+
+// stageTombstone 暂存第 i 条数据将记录 prev 标记为已删除的操作，不检查 prev 是否过期（过期清理使用），
+// 仅在需要放弃整个批次时返回错误
+func (b *batch[T]) stageTombstone(i int, prev *types.Record[T]) error {
+	next := *prev
+	next.Meta.Deleted = true
+	next.Meta.UpdatedAt = time.Now().UnixNano()
+	return b.stage(i, walOpDelete, prev, &next)
+}
+
+// liveErr 返回记录不可更新或删除的原因：不存在或已过期（尚未被清理）时为 ErrNotFound，
+// 已删除时为 ErrRecordDeleted；记录可以修改时返回 nil
+func liveErr[T any](record *types.Record[T], now int64) error {
+	switch {
+	case record == nil:
+		return errors.ErrNotFound
+	case record.Meta.Deleted:
+		return errors.ErrRecordDeleted
+	case record.Meta.Expired(now):
+		return errors.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchAllOrNothingRollsBack(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{})
+	defer s.Close()
+	s.IndexManager.Register("Name", func(r *types.Record[walItem]) interface{} { return r.Data.Name }, IndexUnique)
+
+	a, err := s.Insert(ctx, walItem{Name: "a"})
+	require.NoError(t, err)
+	b, err := s.Insert(ctx, walItem{Name: "b"})
+	require.NoError(t, err)
+
+	// 第二条与第一条冲突，整批都不生效
+	results, err := s.InsertMany(ctx, []walItem{{Name: "c"}, {Name: "c"}, {Name: "d"}}, true)
+	assert.ErrorIs(t, err, errors.ErrDuplicateKey)
+	require.Len(t, results, 3)
+	for _, r := range results {
+		assert.Nil(t, r.Record)
+	}
+	assert.Equal(t, 2, s.AliveCount())
+	assert.Empty(t, s.IndexManager.Query("Name", "c"))
+
+	// 前面的更新已暂存，后面的 ID 不存在
+	_, err = s.UpdateMany(ctx, []BatchUpdate[walItem]{{ID: a.ID, Data: walItem{Name: "x"}}, {ID: 999, Data: walItem{Name: "y"}}}, true)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	got, err := s.Get(ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "a", got.Data.Name)
+	assert.Empty(t, s.IndexManager.Query("Name", "x"))
+
+	_, err = s.DeleteMany(ctx, []uint64{a.ID, b.ID, a.ID}, true)
+	assert.ErrorIs(t, err, errors.ErrRecordDeleted)
+	assert.Equal(t, 2, s.AliveCount())
+
+	// 不要求全部成功时跳过失败的数据
+	results, err = s.DeleteMany(ctx, []uint64{a.ID, 999, b.ID}, false)
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, errors.ErrNotFound)
+	assert.NoError(t, results[2].Err)
+	assert.True(t, results[2].Record.Meta.Deleted)
+	assert.Equal(t, 0, s.AliveCount())
+}
+
+func TestUpdateManyAppliesRepeatedIDsInOrder(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{EnableVersioning: true})
+	defer s.Close()
+
+	rec, err := s.Insert(ctx, walItem{Name: "a"})
+	require.NoError(t, err)
+
+	results, err := s.UpdateMany(ctx, []BatchUpdate[walItem]{
+		{ID: rec.ID, Data: walItem{Name: "b"}},
+		{ID: rec.ID, Data: walItem{Name: "c"}},
+	}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "b", results[0].Record.Data.Name)
+	assert.Equal(t, "c", results[1].Record.Data.Name)
+
+	got, err := s.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "c", got.Data.Name)
+	assert.Equal(t, rec.Version+2, got.Version)
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ldChengYi/EasyDB/core/ds"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/ldChengYi/EasyDB/util"
+)
+
+// EvictionPolicy 超出容量上限时选择淘汰记录的策略
+type EvictionPolicy int
+
+const (
+	EvictOldest            EvictionPolicy = iota // 按创建时间淘汰最早的记录（环形缓冲）
+	EvictLeastRecentlyRead                       // 淘汰最久未被 Get 读取的记录（LRU）
+	EvictLowestPriority                          // 淘汰 Priority 回调返回值最小的记录
+)
+
+// evictor 维护容量统计和淘汰顺序，所有方法由 Store 在写锁内调用（touch 除外）
+type evictor[T any] struct {
+	policy     EvictionPolicy
+	maxRecords int
+	maxBytes   int64
+	bytes      int64 // 存活记录的估算字节数，仅在 maxBytes > 0 时统计
+
+	sizeOf   func(T) int64
+	priority func(*types.Record[T]) int64
+	onEvict  func(*types.Record[T])
+
+	priorityIndex *ds.SkipList // 优先级 -> 存活记录 ID
+
+	lruMu    sync.Mutex // Get 在读锁下更新 LRU 顺序
+	lru      *list.List // 最近读取的在前
+	lruItems map[uint64]*list.Element
+}
+
+// newEvictor 根据配置创建淘汰器，未设置任何上限时返回 nil
+func newEvictor[T any](opts Options) *evictor[T] {
+	if opts.MaxRecords <= 0 && opts.MaxBytes <= 0 {
+		return nil
+	}
+
+	e := &evictor[T]{
+		policy:     opts.Eviction,
+		maxRecords: opts.MaxRecords,
+		maxBytes:   opts.MaxBytes,
+		sizeOf:     defaultRecordSize[T],
+	}
+	if fn, ok := opts.RecordSize.(func(T) int64); ok && fn != nil {
+		e.sizeOf = fn
+	}
+	if fn, ok := opts.OnEvict.(func(*types.Record[T])); ok {
+		e.onEvict = fn
+	}
+
+	switch e.policy {
+	case EvictLeastRecentlyRead:
+		e.lru = list.New()
+		e.lruItems = make(map[uint64]*list.Element)
+	case EvictLowestPriority:
+		if fn, ok := opts.Priority.(func(*types.Record[T]) int64); ok && fn != nil {
+			e.priority = fn
+			e.priorityIndex = ds.NewSkipList(util.Compare)
+		} else {
+			// 未提供优先级回调时退化为按创建时间淘汰
+			e.policy = EvictOldest
+		}
+	}
+	return e
+}
+
+// defaultRecordSize 以 JSON 编码后的长度估算数据大小
+func defaultRecordSize[T any](data T) int64 {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return 0
+	}
+	return int64(len(payload))
+}
+
+// added 登记新的存活记录
+func (e *evictor[T]) added(record *types.Record[T]) {
+	if e.maxBytes > 0 {
+		e.bytes += e.sizeOf(record.Data)
+	}
+	switch e.policy {
+	case EvictLeastRecentlyRead:
+		e.lruMu.Lock()
+		e.lruItems[record.ID] = e.lru.PushFront(record.ID)
+		e.lruMu.Unlock()
+	case EvictLowestPriority:
+		e.priorityIndex.Insert(e.priority(record), record.ID)
+	}
+}
+
+// removed 移除不再存活的记录
+func (e *evictor[T]) removed(record *types.Record[T]) {
+	if e.maxBytes > 0 {
+		e.bytes -= e.sizeOf(record.Data)
+	}
+	switch e.policy {
+	case EvictLeastRecentlyRead:
+		e.lruMu.Lock()
+		if elem, ok := e.lruItems[record.ID]; ok {
+			e.lru.Remove(elem)
+			delete(e.lruItems, record.ID)
+		}
+		e.lruMu.Unlock()
+	case EvictLowestPriority:
+		e.priorityIndex.Delete(e.priority(record), record.ID)
+	}
+}
+
+// updated 记录内容变化后更新大小和优先级，LRU 顺序不变
+func (e *evictor[T]) updated(old, record *types.Record[T]) {
+	if e.maxBytes > 0 {
+		e.bytes += e.sizeOf(record.Data) - e.sizeOf(old.Data)
+	}
+	if e.policy == EvictLowestPriority {
+		e.priorityIndex.Delete(e.priority(old), old.ID)
+		e.priorityIndex.Insert(e.priority(record), record.ID)
+	}
+}
+
+// touch 将记录标记为最近读取（调用方持有读锁或写锁）
+func (e *evictor[T]) touch(id uint64) {
+	if e.policy != EvictLeastRecentlyRead {
+		return
+	}
+	e.lruMu.Lock()
+	if elem, ok := e.lruItems[id]; ok {
+		e.lru.MoveToFront(elem)
+	}
+	e.lruMu.Unlock()
+}
+
+// victims 按淘汰顺序选出足以让存储回到上限以内的记录 ID，跳过 keep 中的记录
+func (e *evictor[T]) victims(s *Store[T], keep map[uint64]*types.Record[T]) []uint64 {
+	excess := 0
+	if e.maxRecords > 0 {
+		excess = s.alive.Len() - e.maxRecords
+	}
+	var excessBytes int64
+	if e.maxBytes > 0 {
+		excessBytes = e.bytes - e.maxBytes
+	}
+	if excess <= 0 && excessBytes <= 0 {
+		return nil
+	}
+
+	var ids []uint64
+	take := func(id uint64) bool {
+		if _, ok := keep[id]; ok {
+			return true
+		}
+		ids = append(ids, id)
+		excess--
+		if excessBytes > 0 {
+			excessBytes -= e.sizeOf(s.recordLocked(id).Data)
+		}
+		return excess > 0 || excessBytes > 0
+	}
+	visit := func(_ interface{}, set map[uint64]struct{}) bool {
+		for id := range set {
+			if !take(id) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch e.policy {
+	case EvictLeastRecentlyRead:
+		e.lruMu.Lock()
+		for elem := e.lru.Back(); elem != nil; elem = elem.Prev() {
+			if !take(elem.Value.(uint64)) {
+				break
+			}
+		}
+		e.lruMu.Unlock()
+	case EvictLowestPriority:
+		e.priorityIndex.Range(nil, nil, true, true, visit)
+	default:
+		s.createdIndex.Range(nil, nil, true, true, visit)
+	}
+	return ids
+}
+
+// enforceLimitsLocked 在写入生效后淘汰超出上限的记录。written 为本次写入的记录，它们已返回给调用方，
+// 不会被淘汰；其余记录不足以回到上限以内时暂时超出上限，留到下一次写入时处理。
+// 写入本身已经生效，淘汰失败（写日志失败）时交给 OnError，下一次写入时会重试（调用方持有写锁）
+func (s *Store[T]) enforceLimitsLocked(written map[uint64]*types.Record[T]) {
+	if err := s.evictLocked(written); err != nil {
+		s.reportError(err)
+	}
+}
+
+// evictLocked 淘汰超出容量上限的记录，删除与 DeleteMany 走相同的路径，
+// 之后对每条被淘汰的记录调用 OnEvict，keep 中的记录不会被淘汰（调用方持有写锁）
+func (s *Store[T]) evictLocked(keep map[uint64]*types.Record[T]) error {
+	if s.evictor == nil {
+		return nil
+	}
+
+	ids := s.evictor.victims(s, keep)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// 淘汰对象可能已过期但尚未清理，同样需要删除
+	b := s.newBatch(len(ids), false)
+	for i, id := range ids {
+		if prev := b.current(id); prev != nil && !prev.Meta.Deleted {
+			_ = b.stageTombstone(i, prev)
+		}
+	}
+	results, err := b.commit()
+	if err != nil {
+		return fmt.Errorf("evict: %w", err)
+	}
+
+	if s.evictor.onEvict != nil {
+		for _, r := range results {
+			if r.Record != nil {
+				s.evictor.onEvict(r.Record)
+			}
+		}
+	}
+	return nil
+}
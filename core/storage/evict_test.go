@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictionFailureIsReported(t *testing.T) {
+	ctx := context.Background()
+	var reported []error
+	s, err := Open[walItem](Options{
+		WALPath:    t.TempDir() + "/db.wal",
+		MaxRecords: 2,
+		OnError:    func(err error) { reported = append(reported, err) },
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = s.Insert(ctx, walItem{Name: "a", Age: i})
+		require.NoError(t, err)
+	}
+	require.Empty(t, reported)
+
+	// 关闭日志文件并调低上限，使淘汰时写日志失败
+	require.NoError(t, s.wal.file.Close())
+	s.Lock()
+	s.evictor.maxRecords = 1
+	s.enforceLimitsLocked(nil)
+	s.Unlock()
+
+	require.Len(t, reported, 1)
+	assert.Contains(t, reported[0].Error(), "evict")
+	assert.Equal(t, 2, s.AliveCount())
+	_ = s.Close()
+}
+
+func TestEvictionKeepsWrittenRecords(t *testing.T) {
+	ctx := context.Background()
+	var evicted []int
+	s := New[walItem](Options{
+		MaxRecords: 2,
+		Eviction:   EvictLowestPriority,
+		Priority:   func(r *types.Record[walItem]) int64 { return int64(r.Data.Age) },
+		OnEvict:    func(r *types.Record[walItem]) { evicted = append(evicted, r.Data.Age) },
+	})
+	defer s.Close()
+
+	for _, age := range []int{5, 6} {
+		_, err := s.Insert(ctx, walItem{Name: "a", Age: age})
+		require.NoError(t, err)
+	}
+
+	// 新记录优先级最低，但已经返回给调用方，淘汰其余记录中优先级最低的
+	low, err := s.Insert(ctx, walItem{Name: "b", Age: 1})
+	require.NoError(t, err)
+	_, err = s.Get(ctx, low.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{5}, evicted)
+
+	// 批量写入超过上限时保留全部新记录，下一次写入再淘汰
+	results, err := s.InsertMany(ctx, []walItem{{Age: 2}, {Age: 3}, {Age: 4}}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []int{5, 1, 6}, evicted)
+	for _, r := range results {
+		_, err = s.Get(ctx, r.Record.ID)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, s.AliveCount())
+
+	_, err = s.Insert(ctx, walItem{Age: 9})
+	require.NoError(t, err)
+	assert.Equal(t, []int{5, 1, 6, 2, 3}, evicted)
+	assert.Equal(t, 2, s.AliveCount())
+}
+
+func TestListCountsAsRead(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{MaxRecords: 2, Eviction: EvictLeastRecentlyRead})
+	defer s.Close()
+
+	first, err := s.Insert(ctx, walItem{Name: "a"})
+	require.NoError(t, err)
+	second, err := s.Insert(ctx, walItem{Name: "b"})
+	require.NoError(t, err)
+
+	records, _, err := s.List(ctx, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, first.ID, records[0].ID)
+
+	_, err = s.Insert(ctx, walItem{Name: "c"})
+	require.NoError(t, err)
+	_, err = s.Get(ctx, first.ID)
+	assert.NoError(t, err)
+	_, err = s.Get(ctx, second.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
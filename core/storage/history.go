@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// addHistory 保存记录被更新或删除前的状态，超出 historyLimit 时丢弃最旧的（调用方持有写锁）。
+// 记录对象写入后不再修改，这里直接保存指针。
+func (s *Store[T]) addHistory(old *types.Record[T]) {
+	if s.historyLimit <= 0 {
+		return
+	}
+
+	versions := s.history[old.ID]
+	if len(versions) < s.historyLimit {
+		s.history[old.ID] = append(versions, old)
+		return
+	}
+	copy(versions, versions[1:])
+	versions[len(versions)-1] = old
+}
+
+// GetVersion 获取记录的指定版本，版本为当前版本或仍在历史中时返回，
+// 否则返回 ErrVersionNotFound。删除不产生新版本，已删除记录的最后一个版本仍可读取。
+func (s *Store[T]) GetVersion(ctx context.Context, id uint64, version uint64) (*types.Record[T], error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	current := s.recordLocked(id)
+	if current == nil {
+		return nil, errors.ErrNotFound
+	}
+	if !current.Meta.Deleted && current.Version == version {
+		return s.export(current), nil
+	}
+
+	versions := s.history[id]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if rec := versions[i]; !rec.Meta.Deleted && rec.Version == version {
+			return s.export(rec), nil
+		}
+	}
+	return nil, errors.ErrVersionNotFound
+}
+
+// History 返回记录保留的所有状态，从旧到新排列，最后一个为当前状态（已删除时为删除标记）
+func (s *Store[T]) History(ctx context.Context, id uint64) ([]*types.Record[T], error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	current := s.recordLocked(id)
+	if current == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	versions := s.history[id]
+	records := make([]*types.Record[T], 0, len(versions)+1)
+	for _, rec := range versions {
+		records = append(records, s.export(rec))
+	}
+	return append(records, s.export(current)), nil
+}
+
+// GetAsOf 返回记录在时间 at 时的状态。
+// 当时尚未创建或已过期时返回 ErrNotFound，已删除时返回 ErrRecordDeleted，
+// 当时的版本已不在保留的历史中时返回 ErrVersionNotFound。
+func (s *Store[T]) GetAsOf(ctx context.Context, id uint64, at time.Time) (*types.Record[T], error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	record, err := s.asOfLocked(id, at.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	return s.export(record), nil
+}
+
+// asOfLocked 返回记录在时间 at 时的状态（调用方持有锁）。
+// 每个状态从它的 UpdatedAt 开始生效，直到下一个状态的 UpdatedAt。
+func (s *Store[T]) asOfLocked(id uint64, at int64) (*types.Record[T], error) {
+	current := s.recordLocked(id)
+	if current == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	record := current
+	if record.Meta.UpdatedAt > at {
+		record = nil
+		versions := s.history[id]
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i].Meta.UpdatedAt <= at {
+				record = versions[i]
+				break
+			}
+		}
+		if record == nil {
+			if current.Meta.CreatedAt > at {
+				return nil, errors.ErrNotFound
+			}
+			return nil, errors.ErrVersionNotFound
+		}
+	}
+
+	if record.Meta.Deleted {
+		return nil, errors.ErrRecordDeleted
+	}
+	if record.Meta.Expired(at) {
+		return nil, errors.ErrNotFound
+	}
+	return record, nil
+}
+
+// ScanAsOf 遍历在时间 at 时存活的记录（顺序不保证），fn 返回 false 时停止。
+// 会检查全部记录（含已删除的），当时的版本已不在历史中或已被 Compact 回收的记录会被跳过。
+// 与 ReadView.Scan 一样分批持有读锁，fn 在锁外调用，收到的是内部记录，不能修改。
+func (s *Store[T]) ScanAsOf(at time.Time, fn func(*types.Record[T]) bool) {
+	ts := at.UnixNano()
+
+	s.RLock()
+	ids := make([]uint64, len(s.data))
+	for i, rec := range s.data {
+		ids[i] = rec.ID
+	}
+	s.RUnlock()
+
+	batch := make([]*types.Record[T], 0, viewScanChunk)
+	for start := 0; start < len(ids); start += viewScanChunk {
+		end := min(start+viewScanChunk, len(ids))
+
+		batch = batch[:0]
+		s.RLock()
+		for _, id := range ids[start:end] {
+			if record, err := s.asOfLocked(id, ts); err == nil {
+				batch = append(batch, record)
+			}
+		}
+		s.RUnlock()
+
+		for _, record := range batch {
+			if !fn(record) {
+				return
+			}
+		}
+	}
+}
@@ -3,8 +3,11 @@ package storage
 import (
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/ldChengYi/EasyDB/core/ds"
+	"github.com/ldChengYi/EasyDB/core/errors"
 	"github.com/ldChengYi/EasyDB/core/types"
 	"github.com/ldChengYi/EasyDB/util"
 )
@@ -16,8 +19,14 @@ const (
 	IndexExact     IndexType = "exact"     // 精确匹配
 	IndexPrefix    IndexType = "prefix"    // 前缀匹配
 	IndexSubstring IndexType = "substring" // 包含匹配
+	IndexOrdered   IndexType = "ordered"   // 有序索引（范围查询）
+	IndexNgram     IndexType = "ngram"     // n-gram 子串索引（按字符切分，内存线性增长）
+	IndexUnique    IndexType = "unique"    // 唯一约束（同时提供精确匹配）
 )
 
+// defaultNgramSize n-gram 索引默认的 gram 长度
+const defaultNgramSize = 3
+
 // FieldIndex 表示某字段的索引结构（支持多个类型）
 type FieldIndex[T any] struct {
 	extractor func(*types.Record[T]) interface{}
@@ -25,12 +34,19 @@ type FieldIndex[T any] struct {
 	exact    map[interface{}]map[uint64]struct{} // 精确匹配索引
 	inverted map[string]map[uint64]struct{}      // 子串倒排索引
 	trie     *ds.Trie                            // 前缀匹配索引
+	ordered  *ds.SkipList                        // 有序索引（范围查询）
+	ngram    *ds.NGramIndex                      // n-gram 子串索引
+	unique   map[interface{}]uint64              // 唯一约束：值 -> 存活记录 ID
 }
 
-// IndexManager 管理所有字段的索引
+// IndexManager 管理所有字段的索引。
+// 写入由 Store 在写锁内调用，查询可能与写入并发，因此由 mu 保护索引内容；
+// 字段和索引类型只在 Register 时设置，之后只读。
 type IndexManager[T any] struct {
+	mu         sync.RWMutex
 	indexes    map[string]*FieldIndex[T] // fieldName -> 索引结构
 	fieldTypes map[string]reflect.Type
+	hasUnique  bool // 是否存在唯一约束字段
 }
 
 // NewIndexManager 构造一个空的索引管理器
@@ -52,9 +68,21 @@ func (im *IndexManager[T]) Register(field string, extractor func(*types.Record[T
 			fi.trie = ds.NewTrie()
 		case IndexSubstring:
 			fi.inverted = make(map[string]map[uint64]struct{})
+		case IndexOrdered:
+			fi.ordered = ds.NewSkipList(util.Compare)
+		case IndexNgram:
+			fi.ngram = ds.NewNGramIndex(defaultNgramSize)
+		case IndexUnique:
+			fi.unique = make(map[interface{}]uint64)
+			im.hasUnique = true
 		}
 	}
 
+	// 唯一约束、前缀和子串索引字段同样支持等值查询（按完整值精确匹配）
+	if (fi.unique != nil || fi.trie != nil || fi.inverted != nil) && fi.exact == nil {
+		fi.exact = make(map[interface{}]map[uint64]struct{})
+	}
+
 	im.indexes[field] = fi
 
 	if im.fieldTypes == nil {
@@ -66,18 +94,37 @@ func (im *IndexManager[T]) Register(field string, extractor func(*types.Record[T
 
 // AddIndexByRecord 将记录添加到所有索引中
 func (im *IndexManager[T]) AddIndexByRecord(record *types.Record[T]) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.addLocked(record)
+}
+
+// addLocked 将记录添加到所有索引中（调用方持有 mu）
+func (im *IndexManager[T]) addLocked(record *types.Record[T]) {
 	id := record.ID
 	for _, fi := range im.indexes {
 		val := fi.extractor(record)
 
 		// 精确索引
-		if fi.exact != nil {
+		// 不可比较的值（如切片）无法作为 map 的键，不进入精确索引
+		if fi.exact != nil && hashable(val) {
 			if _, ok := fi.exact[val]; !ok {
 				fi.exact[val] = make(map[uint64]struct{})
 			}
 			fi.exact[val][id] = struct{}{}
 		}
 
+		// 唯一约束
+		if fi.unique != nil {
+			fi.unique[val] = id
+		}
+
+		// 有序索引
+		// 无法比较的值（如结构体）不进入有序索引，范围查询不会返回该记录
+		if fi.ordered != nil && util.CanCompare(val) {
+			fi.ordered.Insert(val, id)
+		}
+
 		// 前缀索引
 		if fi.trie != nil {
 			valStr, err := util.SafeToString(val)
@@ -105,17 +152,33 @@ func (im *IndexManager[T]) AddIndexByRecord(record *types.Record[T]) {
 				}
 			}
 		}
+
+		// n-gram 索引
+		if fi.ngram != nil {
+			valStr, err := util.SafeToString(val)
+			if err != nil {
+				continue
+			}
+			fi.ngram.Insert(valStr, id)
+		}
 	}
 }
 
 // RemoveIndexByRecord 将记录从所有索引中移除
 func (im *IndexManager[T]) RemoveIndexByRecord(record *types.Record[T]) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.removeLocked(record)
+}
+
+// removeLocked 从所有索引中移除记录（调用方持有 mu）
+func (im *IndexManager[T]) removeLocked(record *types.Record[T]) {
 	id := record.ID
 	for _, fi := range im.indexes {
 		val := fi.extractor(record)
 
 		// 精确索引
-		if fi.exact != nil {
+		if fi.exact != nil && hashable(val) {
 			if idSet, ok := fi.exact[val]; ok {
 				delete(idSet, id)
 				if len(idSet) == 0 {
@@ -124,12 +187,21 @@ func (im *IndexManager[T]) RemoveIndexByRecord(record *types.Record[T]) {
 			}
 		}
 
+		// 唯一约束
+		if fi.unique != nil && fi.unique[val] == id {
+			delete(fi.unique, val)
+		}
+
+		// 有序索引
+		if fi.ordered != nil && util.CanCompare(val) {
+			fi.ordered.Delete(val, id)
+		}
+
 		// 前缀索引
 		if fi.trie != nil {
 			valStr, err := util.SafeToString(val)
 			if err != nil {
-				// 可记录日志 / 报错 / 跳过该字段索引
-				fmt.Printf("Index warning: field value %v is not string-convertible: %v\n", val, err)
+				// 插入时同样跳过了该值
 				continue
 			}
 			fi.trie.Delete(valStr, id)
@@ -139,8 +211,7 @@ func (im *IndexManager[T]) RemoveIndexByRecord(record *types.Record[T]) {
 		if fi.inverted != nil {
 			valStr, err := util.SafeToString(val)
 			if err != nil {
-				fmt.Printf("Index warning: field value %v is not string-convertible: %v\n", val, err)
-				// 可记录日志 / 报错 / 跳过该字段索引
+				// 插入时同样跳过了该值
 				continue
 			}
 			for i := 0; i <= len(valStr)-1; i++ {
@@ -155,74 +226,390 @@ func (im *IndexManager[T]) RemoveIndexByRecord(record *types.Record[T]) {
 				}
 			}
 		}
+
+		// n-gram 索引
+		if fi.ngram != nil {
+			valStr, err := util.SafeToString(val)
+			if err != nil {
+				continue
+			}
+			fi.ngram.Delete(valStr, id)
+		}
 	}
 }
 
 // UpdateIndexByRecord 用新数据更新旧数据索引
 func (im *IndexManager[T]) UpdateIndexByRecord(oldRecord, newRecord *types.Record[T]) {
-	im.RemoveIndexByRecord(oldRecord)
-	im.AddIndexByRecord(newRecord)
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.removeLocked(oldRecord)
+	im.addLocked(newRecord)
 }
 
-// Query 查询索引数据（优先精确 > 前缀 > 子串）
-func (im *IndexManager[T]) Query(field string, keyword interface{}) map[uint64]struct{} {
-	if fi, ok := im.indexes[field]; ok {
-		// 精确匹配
-		if fi.exact != nil {
-			if set, ok := fi.exact[keyword]; ok {
-				return set
-			}
+// CheckUnique 校验一批记录变更是否违反唯一约束。
+// changes 为记录 ID 到变更后状态的映射，nil 或已删除表示该记录将不再存活；
+// 批次内的记录之间、以及与批次外的存活记录之间都不能出现重复值。
+// 违反约束时返回包装了 ErrDuplicateKey 的错误。
+func (im *IndexManager[T]) CheckUnique(changes map[uint64]*types.Record[T]) error {
+	if !im.hasUnique {
+		return nil
+	}
+
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
+	for field, fi := range im.indexes {
+		if fi.unique == nil {
+			continue
 		}
-		// 前缀匹配
-		if fi.trie != nil {
-			valStr, err := util.SafeToString(keyword)
-			if err != nil {
-				// 可记录日志 / 报错 / 跳过该字段索引
-				fmt.Printf("Index warning: field value %v is not string-convertible: %v\n", keyword, err)
-				return nil
+
+		local := make(map[interface{}]uint64, len(changes))
+		for id, record := range changes {
+			if record == nil || record.Meta.Deleted {
+				continue
 			}
-			if set := fi.trie.QueryPrefix(valStr); set != nil {
-				return set
+			val := fi.extractor(record)
+			if other, ok := local[val]; ok && other != id {
+				return fmt.Errorf("%w: field %s value %v", errors.ErrDuplicateKey, field, val)
 			}
-		}
-		// 子串匹配
-		if fi.inverted != nil {
-			valStr, err := util.SafeToString(keyword)
-			if err != nil {
-				// 可记录日志 / 报错 / 跳过该字段索引
-				fmt.Printf("Index warning: field value %v is not string-convertible: %v\n", keyword, err)
-				return nil
+			local[val] = id
+
+			// 持有该值的记录若也在本批次中变更，其新值由 local 检查
+			if owner, ok := fi.unique[val]; ok && owner != id {
+				if _, changed := changes[owner]; !changed {
+					return fmt.Errorf("%w: field %s value %v", errors.ErrDuplicateKey, field, val)
+				}
 			}
-			if set, ok := fi.inverted[valStr]; ok {
-				return set
+		}
+	}
+	return nil
+}
+
+// uniqueBatch 在批量写入中逐条校验唯一约束：记录批次内已接受的变更占用和释放的值，
+// 使每条变更的校验只与其自身的字段数有关，而与批次大小无关
+type uniqueBatch[T any] struct {
+	im       *IndexManager[T]
+	claimed  map[string]map[interface{}]uint64   // 字段 -> 值 -> 批次内持有该值的记录 ID
+	released map[string]map[interface{}]struct{} // 字段 -> 原持有者在批次内已放弃的值
+}
+
+// newUniqueBatch 创建批量唯一约束校验器
+func (im *IndexManager[T]) newUniqueBatch() *uniqueBatch[T] {
+	return &uniqueBatch[T]{
+		im:       im,
+		claimed:  make(map[string]map[interface{}]uint64),
+		released: make(map[string]map[interface{}]struct{}),
+	}
+}
+
+// check 校验记录 id 变为 next 后是否与其他记录冲突，next 为 nil 或已删除时总是通过
+func (b *uniqueBatch[T]) check(id uint64, next *types.Record[T]) error {
+	if !b.im.hasUnique || next == nil || next.Meta.Deleted {
+		return nil
+	}
+
+	for field, fi := range b.im.indexes {
+		if fi.unique == nil {
+			continue
+		}
+		val := fi.extractor(next)
+		if owner, ok := b.claimed[field][val]; ok {
+			if owner != id {
+				return fmt.Errorf("%w: field %s value %v", errors.ErrDuplicateKey, field, val)
 			}
+			continue
+		}
+		if _, ok := b.released[field][val]; ok {
+			continue
+		}
+		if owner, ok := fi.unique[val]; ok && owner != id {
+			return fmt.Errorf("%w: field %s value %v", errors.ErrDuplicateKey, field, val)
 		}
 	}
 	return nil
 }
 
-// QueryPrefix 仅使用前缀索引进行查询
+// accept 登记记录 id 从 prev 变为 next（已通过 check），prev/next 为 nil 或已删除表示不存活
+func (b *uniqueBatch[T]) accept(id uint64, prev, next *types.Record[T]) {
+	if !b.im.hasUnique {
+		return
+	}
+
+	for field, fi := range b.im.indexes {
+		if fi.unique == nil {
+			continue
+		}
+		if prev != nil && !prev.Meta.Deleted {
+			val := fi.extractor(prev)
+			if b.claimed[field][val] == id {
+				delete(b.claimed[field], val)
+			}
+			if b.released[field] == nil {
+				b.released[field] = make(map[interface{}]struct{})
+			}
+			b.released[field][val] = struct{}{}
+		}
+		if next != nil && !next.Meta.Deleted {
+			if b.claimed[field] == nil {
+				b.claimed[field] = make(map[interface{}]uint64)
+			}
+			b.claimed[field][fi.extractor(next)] = id
+		}
+	}
+}
+
+// LookupUnique 通过唯一约束查找持有该值的存活记录 ID
+func (im *IndexManager[T]) LookupUnique(field string, val interface{}) (uint64, bool) {
+	fi, ok := im.indexes[field]
+	if !ok || fi.unique == nil {
+		return 0, false
+	}
+
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	id, ok := fi.unique[val]
+	return id, ok
+}
+
+// copySet 复制索引中的 ID 集合，查询结果不能与索引共享内存（索引可能被并发修改）
+func copySet(set map[uint64]struct{}) map[uint64]struct{} {
+	if set == nil {
+		return nil
+	}
+	out := make(map[uint64]struct{}, len(set))
+	for id := range set {
+		out[id] = struct{}{}
+	}
+	return out
+}
+
+// hashable 判断值能否作为 map 的键
+func hashable(val interface{}) bool {
+	return val == nil || reflect.TypeOf(val).Comparable()
+}
+
+// MatchMode 条件对字段值的匹配方式，只取决于字段注册的索引类型，与索引当前的内容无关，
+// 因此逐条校验历史版本（读视图、AsOf）或变更事件时与索引查询的结果一致
+type MatchMode int
+
+const (
+	MatchNone      MatchMode = iota // 没有可用的索引，不匹配任何记录
+	MatchExact                      // 与比较值相等
+	MatchPrefix                     // 以比较值开头
+	MatchSubstring                  // 包含比较值
+)
+
+// Match 按匹配方式判断字段值 val 是否满足比较值 keyword，用于逐条校验记录
+func (m MatchMode) Match(val, keyword interface{}) bool {
+	switch m {
+	case MatchExact:
+		if !hashable(val) || !hashable(keyword) {
+			return false
+		}
+		return val == keyword
+	case MatchPrefix, MatchSubstring:
+		valStr, err := util.SafeToString(val)
+		if err != nil {
+			return false
+		}
+		keyStr, err := util.SafeToString(keyword)
+		if err != nil {
+			return false
+		}
+		if m == MatchPrefix {
+			return strings.HasPrefix(valStr, keyStr)
+		}
+		return strings.Contains(valStr, keyStr)
+	default:
+		return false
+	}
+}
+
+// ContainsMode 返回包含查询的匹配方式：注册了 n-gram 或子串索引时按子串匹配，
+// 只有前缀索引时按前缀匹配，都没有时为 MatchNone
+func (im *IndexManager[T]) ContainsMode(field string) MatchMode {
+	fi, ok := im.indexes[field]
+	if !ok {
+		return MatchNone
+	}
+	switch {
+	case fi.ngram != nil, fi.inverted != nil:
+		return MatchSubstring
+	case fi.trie != nil:
+		return MatchPrefix
+	}
+	return MatchNone
+}
+
+// Query 等值查询，返回字段值与 keyword 相等的记录（新分配的集合）。
+// 精确、唯一、前缀和子串索引都会维护等值查找表；字段只有 n-gram 或有序索引时返回 nil。
+func (im *IndexManager[T]) Query(field string, keyword interface{}) map[uint64]struct{} {
+	fi, ok := im.indexes[field]
+	if !ok || fi.exact == nil || !hashable(keyword) {
+		return nil
+	}
+
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	return copySet(fi.exact[keyword])
+}
+
+// QueryPrefix 仅使用前缀索引进行查询，返回新分配的集合
 func (im *IndexManager[T]) QueryPrefix(field string, prefix string) map[uint64]struct{} {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
 	if fi, ok := im.indexes[field]; ok {
 		if fi.trie != nil {
-			return fi.trie.QueryPrefix(prefix)
+			return copySet(fi.trie.QueryPrefix(prefix))
 		}
 	}
 	return nil
 }
 
-// QuerySubstring 仅使用子串倒排索引进行查询
+// QuerySubstring 仅使用子串倒排索引进行查询，返回新分配的集合
 func (im *IndexManager[T]) QuerySubstring(field string, substr string) map[uint64]struct{} {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
 	if fi, ok := im.indexes[field]; ok {
 		if fi.inverted != nil {
 			if set, ok := fi.inverted[substr]; ok {
-				return set
+				return copySet(set)
 			}
 		}
 	}
 	return nil
 }
 
+// QueryNgram 仅使用 n-gram 索引进行子串查询，字段未注册 n-gram 索引时返回 nil
+func (im *IndexManager[T]) QueryNgram(field string, substr string) map[uint64]struct{} {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
+	if fi, ok := im.indexes[field]; ok {
+		if fi.ngram != nil {
+			return fi.ngram.Query(substr)
+		}
+	}
+	return nil
+}
+
+// HasIndexType 判断字段是否注册了指定类型的索引
+func (im *IndexManager[T]) HasIndexType(field string, t IndexType) bool {
+	fi, ok := im.indexes[field]
+	if !ok {
+		return false
+	}
+
+	switch t {
+	case IndexExact:
+		return fi.exact != nil
+	case IndexPrefix:
+		return fi.trie != nil
+	case IndexSubstring:
+		return fi.inverted != nil
+	case IndexOrdered:
+		return fi.ordered != nil
+	case IndexNgram:
+		return fi.ngram != nil
+	case IndexUnique:
+		return fi.unique != nil
+	default:
+		return false
+	}
+}
+
+// QueryRange 仅使用有序索引进行范围查询，lo/hi 为 nil 表示该侧无界。
+// 字段未注册有序索引时第二个返回值为 false；边界与索引中的值无法比较（如用数字查询时间字段）时返回错误。
+func (im *IndexManager[T]) QueryRange(field string, lo, hi interface{}, includeLo, includeHi bool) (map[uint64]struct{}, bool, error) {
+	fi, ok := im.indexes[field]
+	if !ok || fi.ordered == nil {
+		return nil, false, nil
+	}
+
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
+	if key, ok := fi.ordered.First(); ok {
+		for _, bound := range []interface{}{lo, hi} {
+			if bound == nil {
+				continue
+			}
+			if _, err := util.TryCompare(key, bound); err != nil {
+				return nil, true, fmt.Errorf("field %s: range bound: %w", field, err)
+			}
+		}
+	}
+
+	result := make(map[uint64]struct{})
+	fi.ordered.Range(lo, hi, includeLo, includeHi, func(_ interface{}, ids map[uint64]struct{}) bool {
+		for id := range ids {
+			result[id] = struct{}{}
+		}
+		return true
+	})
+	return result, true, nil
+}
+
+// Estimate 估算 Query(field, keyword) 返回的记录数。
+// 字段不存在或不支持等值查询时第二个返回值为 false。
+func (im *IndexManager[T]) Estimate(field string, keyword interface{}) (int, bool) {
+	fi, ok := im.indexes[field]
+	if !ok || fi.exact == nil {
+		return 0, false
+	}
+	if !hashable(keyword) {
+		return 0, true
+	}
+
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	return len(fi.exact[keyword]), true
+}
+
+// EstimateContains 估算包含查询的记录数，使用的索引与 ContainsMode 一致（n-gram > 子串 > 前缀）
+func (im *IndexManager[T]) EstimateContains(field string, substr string) (int, bool) {
+	fi, ok := im.indexes[field]
+	if !ok {
+		return 0, false
+	}
+
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	switch {
+	case fi.ngram != nil:
+		return fi.ngram.Estimate(substr), true
+	case fi.inverted != nil:
+		return len(fi.inverted[substr]), true
+	case fi.trie != nil:
+		return len(fi.trie.QueryPrefix(substr)), true
+	}
+	return 0, false
+}
+
+// EstimateRange 统计有序索引中范围内的记录数，超过 limit 时提前停止并返回 limit。
+// 字段未注册有序索引时第二个返回值为 false。
+func (im *IndexManager[T]) EstimateRange(field string, lo, hi interface{}, includeLo, includeHi bool, limit int) (int, bool) {
+	fi, ok := im.indexes[field]
+	if !ok || fi.ordered == nil {
+		return 0, false
+	}
+
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
+	count := 0
+	fi.ordered.Range(lo, hi, includeLo, includeHi, func(_ interface{}, ids map[uint64]struct{}) bool {
+		count += len(ids)
+		return count < limit
+	})
+	if count > limit {
+		count = limit
+	}
+	return count, true
+}
+
 func (im *IndexManager[T]) GetFieldTypes() map[string]reflect.Type {
 	return im.fieldTypes
 }
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniqueIndexRejectsDuplicates(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{})
+	defer s.Close()
+	s.IndexManager.Register("Name", func(r *types.Record[walItem]) interface{} { return r.Data.Name }, IndexUnique)
+
+	a, err := s.Insert(ctx, walItem{Name: "a"})
+	require.NoError(t, err)
+	b, err := s.Insert(ctx, walItem{Name: "b"})
+	require.NoError(t, err)
+
+	// 插入重复值
+	_, err = s.Insert(ctx, walItem{Name: "a", Age: 1})
+	assert.ErrorIs(t, err, errors.ErrDuplicateKey)
+	assert.Equal(t, 2, s.AliveCount())
+
+	// 更新为其他记录持有的值，记录保持原样
+	_, err = s.Update(ctx, b.ID, walItem{Name: "a"})
+	assert.ErrorIs(t, err, errors.ErrDuplicateKey)
+	got, err := s.Get(ctx, b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "b", got.Data.Name)
+
+	// 更新为自己当前的值不算冲突
+	_, err = s.Update(ctx, a.ID, walItem{Name: "a", Age: 5})
+	require.NoError(t, err)
+
+	// 批量写入中与批次内的其他记录冲突
+	results, err := s.InsertMany(ctx, []walItem{{Name: "c"}, {Name: "c"}}, false)
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, errors.ErrDuplicateKey)
+
+	// 删除后值被释放，可以再次使用
+	require.NoError(t, s.Delete(ctx, a.ID))
+	_, err = s.Update(ctx, b.ID, walItem{Name: "a"})
+	require.NoError(t, err)
+	id, ok := s.IndexManager.LookupUnique("Name", "a")
+	assert.True(t, ok)
+	assert.Equal(t, b.ID, id)
+	_, ok = s.IndexManager.LookupUnique("Name", "b")
+	assert.False(t, ok)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// PrimaryKeyField 主键在索引管理器中注册的字段名，可用于等值查询和排序
+const PrimaryKeyField = "_key"
+
+// keyIDLocked 返回主键对应的存活记录 ID（调用方持有锁）
+func (s *Store[T]) keyIDLocked(key interface{}) (uint64, error) {
+	if s.primaryKey == nil {
+		return 0, fmt.Errorf("%w: primary key is not configured", errors.ErrInvalidInput)
+	}
+	id, ok := s.IndexManager.LookupUnique(PrimaryKeyField, key)
+	if !ok || s.data[s.idMapIndex[id]].Meta.Expired(time.Now().UnixNano()) {
+		return 0, errors.ErrNotFound
+	}
+	return id, nil
+}
+
+// GetByKey 按主键获取存活记录
+func (s *Store[T]) GetByKey(ctx context.Context, key interface{}) (*types.Record[T], error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	id, err := s.keyIDLocked(key)
+	if err != nil {
+		return nil, err
+	}
+	if s.evictor != nil {
+		s.evictor.touch(id)
+	}
+	return s.export(s.data[s.idMapIndex[id]]), nil
+}
+
+// UpdateByKey 按主键更新记录，新数据的主键与其他存活记录冲突时返回 ErrDuplicateKey
+func (s *Store[T]) UpdateByKey(ctx context.Context, key interface{}, data T) (*types.Record[T], error) {
+	s.Lock()
+	defer s.Unlock()
+
+	id, err := s.keyIDLocked(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateLocked(id, data, nil)
+}
+
+// DeleteByKey 按主键删除记录
+func (s *Store[T]) DeleteByKey(ctx context.Context, key interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+
+	id, err := s.keyIDLocked(key)
+	if err != nil {
+		return err
+	}
+	return s.deleteLocked(id, nil)
+}
+
+// Upsert 按 data 的主键插入或替换记录，created 表示是否新建了记录
+func (s *Store[T]) Upsert(ctx context.Context, data T) (record *types.Record[T], created bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.primaryKey == nil {
+		return nil, false, fmt.Errorf("%w: primary key is not configured", errors.ErrInvalidInput)
+	}
+
+	id, err := s.keyIDLocked(s.primaryKey(data))
+	switch err {
+	case nil:
+		record, err = s.updateLocked(id, data, nil)
+		return record, false, err
+	case errors.ErrNotFound:
+		record, err = s.insertLocked(data)
+		return record, err == nil, err
+	default:
+		return nil, false, err
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertByPrimaryKey(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{PrimaryKey: func(v walItem) interface{} { return v.Name }})
+	defer s.Close()
+
+	first, created, err := s.Upsert(ctx, walItem{Name: "a", Age: 1})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	// 主键已存在时替换原记录，ID 不变
+	second, created, err := s.Upsert(ctx, walItem{Name: "a", Age: 2})
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, 1, s.AliveCount())
+
+	got, err := s.GetByKey(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Data.Age)
+
+	// 删除后再 Upsert 会新建记录
+	require.NoError(t, s.DeleteByKey(ctx, "a"))
+	_, err = s.GetByKey(ctx, "a")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	third, created, err := s.Upsert(ctx, walItem{Name: "a", Age: 3})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, first.ID, third.ID)
+
+	// 主键冲突
+	_, err = s.Insert(ctx, walItem{Name: "a"})
+	assert.ErrorIs(t, err, errors.ErrDuplicateKey)
+	_, err = s.Insert(ctx, walItem{Name: "b"})
+	require.NoError(t, err)
+	_, err = s.UpdateByKey(ctx, "b", walItem{Name: "a"})
+	assert.ErrorIs(t, err, errors.ErrDuplicateKey)
+	updated, err := s.UpdateByKey(ctx, "b", walItem{Name: "c", Age: 9})
+	require.NoError(t, err)
+	assert.Equal(t, "c", updated.Data.Name)
+	_, err = s.GetByKey(ctx, "b")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestPrimaryKeyNotConfigured(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{})
+	defer s.Close()
+
+	_, _, err := s.Upsert(ctx, walItem{Name: "a"})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+	_, err = s.GetByKey(ctx, "a")
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+}
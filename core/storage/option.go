@@ -1,6 +1,10 @@
 package storage
 
-import "github.com/ldChengYi/EasyDB/core/types"
+import (
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/types"
+)
 
 type FieldIndexConfig[T any] struct {
 	Field     string                             // 字段名称
@@ -16,6 +20,68 @@ type Options struct {
 	// EnableVersioning 是否启用版本控制
 	EnableVersioning bool
 
+	// HistoryLimit 每条记录保留的旧版本数，<=0 表示不保留；需要启用 EnableVersioning
+	HistoryLimit int
+
 	// 泛型不支持，需要 Store 初始化时断言
 	FieldIndexes any
+
+	// SafeReads 安全读模式：所有返回给调用方的记录都是副本（T 实现 types.Cloner 时深拷贝），
+	// 写入的数据也会先复制，调用方修改返回值或传入的数据不会影响存储和索引
+	SafeReads bool
+
+	// PrimaryKey 主键提取函数 func(T) interface{}，同样在 Store 初始化时断言；
+	// 配置后主键在存活记录中唯一，可通过 GetByKey 等方法按主键访问
+	PrimaryKey any
+
+	// WALPath 预写日志文件路径，为空时不启用持久化（需通过 Open 创建存储）
+	WALPath string
+
+	// SyncWrites 每次写日志后是否立即 fsync，关闭时崩溃可能丢失最近的写入
+	SyncWrites bool
+
+	// SnapshotPath 日志压缩生成的快照路径，默认为 WALPath + ".snap"
+	SnapshotPath string
+
+	// CompactLogSize 日志超过该字节数时触发后台压缩，<=0 表示不按大小触发
+	CompactLogSize int64
+
+	// CompactInterval 定时触发后台压缩的间隔，<=0 表示不定时触发
+	CompactInterval time.Duration
+
+	// TombstoneRatio 已删除记录占 data 的比例超过该值时后台执行 Compact，<=0 表示不自动压缩
+	TombstoneRatio float64
+
+	// TombstoneRetention 已删除记录至少保留的时长，保留期内不会被 Compact 回收，可以 Restore；
+	// 保留期过后由后台任务自动 Compact 回收（每 1/4 保留期检查一次），不依赖 TombstoneRatio。
+	// <=0 表示 Compact 回收所有已删除记录
+	TombstoneRetention time.Duration
+
+	// DefaultTTL 新记录的默认存活时间，<=0 表示不过期；可通过 InsertWithTTL 单独指定
+	DefaultTTL time.Duration
+
+	// ReapInterval 后台清理过期记录的间隔，<=0 时使用 1 秒
+	ReapInterval time.Duration
+
+	// MaxRecords 存活记录数上限，超出时按 Eviction 淘汰，<=0 表示不限制
+	MaxRecords int
+
+	// MaxBytes 存活记录的估算总字节数上限，超出时按 Eviction 淘汰，<=0 表示不限制
+	MaxBytes int64
+
+	// Eviction 淘汰策略，默认按创建时间淘汰最早的记录
+	Eviction EvictionPolicy
+
+	// RecordSize 估算数据大小的函数 func(T) int64，默认使用 JSON 编码后的长度
+	RecordSize any
+
+	// Priority EvictLowestPriority 策略使用的优先级函数 func(*types.Record[T]) int64，值小的先淘汰
+	Priority any
+
+	// OnEvict 记录被淘汰后的通知 func(*types.Record[T])，在写锁内同步调用，不能调用 Store 的方法
+	OnEvict any
+
+	// OnError 接收无法返回给调用方的错误：写入已生效后容量淘汰失败、后台日志压缩或过期清理失败。
+	// 可能在写锁内同步调用，不能调用 Store 的方法；为 nil 时通过标准库 log 输出
+	OnError func(err error)
 }
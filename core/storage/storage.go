@@ -22,4 +22,15 @@ type Storage[T any] interface {
 
 	// List 列出记录
 	List(ctx context.Context, offset, limit int) ([]*types.Record[T], int, error)
+
+	// InsertMany 批量插入记录，allOrNothing 为 true 时任意一条失败则全部不生效
+	InsertMany(ctx context.Context, items []T, allOrNothing bool) ([]BatchResult[T], error)
+
+	// UpdateMany 批量更新记录
+	UpdateMany(ctx context.Context, items []BatchUpdate[T], allOrNothing bool) ([]BatchResult[T], error)
+
+	// DeleteMany 批量删除记录
+	DeleteMany(ctx context.Context, ids []uint64, allOrNothing bool) ([]BatchResult[T], error)
 }
+
+var _ Storage[struct{}] = (*Store[struct{}])(nil)
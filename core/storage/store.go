@@ -2,40 +2,108 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/ldChengYi/EasyDB/core/ds"
 	"github.com/ldChengYi/EasyDB/core/errors"
 	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/ldChengYi/EasyDB/util"
+)
+
+// TimeField 表示记录元数据中可按时间范围查询的字段
+type TimeField int
+
+const (
+	TimeCreated TimeField = iota // RecordMeta.CreatedAt
+	TimeUpdated                  // RecordMeta.UpdatedAt
 )
 
 // Store 内存存储引擎实现
 type Store[T any] struct {
 	sync.RWMutex
-	data          []*types.Record[T]
-	idGen         atomic.Uint64
-	idMapIndex    map[uint64]int
-	aliveIndexes  []int
-	aliveIndexSet map[int]struct{}
+	data       []*types.Record[T]
+	idGen      atomic.Uint64
+	idMapIndex map[uint64]int
+	alive      *ds.RankSet // 存活记录在 data 中的位置
+	unordered  bool        // data 中的位置不再按 ID 升序（如事务提交的记录 ID 早于之后插入的记录），Compact 时恢复
 
 	IndexManager *IndexManager[T]
 	options      Options
+	primaryKey   func(T) interface{} // 主键提取函数，未配置时为 nil
+
+	createdIndex *ds.SkipList // CreatedAt -> 存活记录 ID
+	updatedIndex *ds.SkipList // UpdatedAt -> 存活记录 ID
+	expiryIndex  *ds.SkipList // ExpiresAt -> 设置了过期时间的存活记录 ID
+	reaperOnce   sync.Once    // 首次出现带过期时间的记录时启动后台清理
+	loaded       bool         // 数据加载（快照、日志回放）完成，之后才启动后台任务
+	evictor      *evictor[T]  // 容量上限与淘汰顺序，未设置上限时为 nil
+
+	historyLimit int                           // 每条记录保留的旧版本数，0 表示不保留
+	history      map[uint64][]*types.Record[T] // 记录 ID -> 旧版本（从旧到新）
+
+	wal *wal   // 预写日志，未启用持久化时为 nil
+	lsn uint64 // 最后一条日志的序号
+
+	compactMu sync.Mutex    // 串行化日志压缩和墓碑压缩
+	compactCh chan struct{} // 日志超过阈值时通知后台压缩
+
+	tombCh          chan struct{} // 墓碑比例超过阈值时通知后台执行 Compact
+	compacting      bool          // Compact 正在构建新的 data
+	compactReplaced []uint64      // 构建期间对象被替换（更新、删除、恢复、清除）的记录 ID
+	keptTombstones  int           // 上次 Compact 因保留期留下的墓碑数
+	oldestTombstone int64         // data 中最早的墓碑删除时间（UnixNano），0 表示没有，保留期过后触发回收
+
+	seq         uint64                 // 提交序号，每次修改记录加一，读视图按它区分新旧版本
+	versions    map[uint64]*version[T] // 读视图仍可能需要的旧版本，按记录 ID 组织成链
+	retired     []retiredVersion       // 按失效序号排列的旧版本，用于回收
+	viewMu      sync.Mutex             // 保护 pins
+	pins        map[uint64]int         // 打开的读视图：序号 -> 视图数
+	activeViews atomic.Int32           // 打开的读视图数，为 0 时修改不保留旧版本
+
+	watchers []*watcher[T] // 变更订阅者
+
+	bgStop    chan struct{} // 关闭时通知后台任务退出
+	bgWG      sync.WaitGroup
+	closeOnce sync.Once
 }
 
-// New 创建新的内存存储实例
+// New 创建新的内存存储实例，并按配置启动后台任务（墓碑压缩、过期清理），不再使用时需调用 Close
 func New[T any](opts Options) *Store[T] {
+	store := newStore[T](opts)
+	store.startBackground()
+	return store
+}
+
+// newStore 创建尚未加载数据的存储实例，加载完成后需调用 startBackground
+func newStore[T any](opts Options) *Store[T] {
 	if opts.InitialCapacity <= 0 {
 		opts.InitialCapacity = 1000
 	}
 
 	store := &Store[T]{
-		data:          make([]*types.Record[T], 0, opts.InitialCapacity),
-		idMapIndex:    make(map[uint64]int),
-		aliveIndexes:  make([]int, 0),
-		aliveIndexSet: make(map[int]struct{}),
-		IndexManager:  NewIndexManager[T](), // 初始化新的索引管理器
-		options:       opts,
+		data:         make([]*types.Record[T], 0, opts.InitialCapacity),
+		idMapIndex:   make(map[uint64]int),
+		alive:        ds.NewRankSet(opts.InitialCapacity),
+		IndexManager: NewIndexManager[T](), // 初始化新的索引管理器
+		options:      opts,
+		createdIndex: ds.NewSkipList(util.Compare),
+		updatedIndex: ds.NewSkipList(util.Compare),
+		expiryIndex:  ds.NewSkipList(util.Compare),
+		compactCh:    make(chan struct{}, 1),
+		tombCh:       make(chan struct{}, 1),
+		bgStop:       make(chan struct{}),
+		evictor:      newEvictor[T](opts),
+		versions:     make(map[uint64]*version[T]),
+		pins:         make(map[uint64]int),
+	}
+
+	if opts.EnableVersioning && opts.HistoryLimit > 0 {
+		store.historyLimit = opts.HistoryLimit
+		store.history = make(map[uint64][]*types.Record[T])
 	}
 
 	if list, ok := opts.FieldIndexes.([]FieldIndexConfig[T]); ok {
@@ -44,33 +112,170 @@ func New[T any](opts Options) *Store[T] {
 		}
 	}
 
+	if key, ok := opts.PrimaryKey.(func(T) interface{}); ok && key != nil {
+		store.primaryKey = key
+		store.IndexManager.Register(PrimaryKeyField, func(r *types.Record[T]) interface{} {
+			return key(r.Data)
+		}, IndexUnique)
+	}
+
 	return store
 }
 
+// Open 创建存储实例，配置了 WALPath 时会先加载 SnapshotPath 处的快照，
+// 再回放日志恢复数据，之后的每次写操作都会先追加到日志再生效
+func Open[T any](opts Options) (*Store[T], error) {
+	store := newStore[T](opts)
+	if err := store.loadSnapshotFile(); err != nil {
+		return nil, err
+	}
+	if err := store.attachWAL(); err != nil {
+		return nil, err
+	}
+	store.startBackground()
+	return store, nil
+}
+
+// startBackground 标记数据加载完成并按需启动后台日志压缩和过期清理任务
+func (s *Store[T]) startBackground() {
+	s.loaded = true
+	s.Lock()
+	s.enforceLimitsLocked(nil)
+	s.Unlock()
+	s.startCompactor()
+	s.startTombstoneCompactor()
+	if s.expiryIndex.Len() > 0 {
+		s.reaperOnce.Do(s.startReaper)
+	}
+}
+
+// attachWAL 打开日志文件并回放序号大于当前 lsn 的记录
+func (s *Store[T]) attachWAL() error {
+	if s.options.WALPath == "" {
+		return nil
+	}
+
+	w, err := openWAL(s.options.WALPath, s.options.SyncWrites)
+	if err != nil {
+		return err
+	}
+
+	err = w.replay(func(payload []byte) error {
+		entry, err := decodeWALEntry[T](payload)
+		if err != nil {
+			return err
+		}
+		// 已包含在快照中的日志直接跳过（压缩时写完快照、截断日志前崩溃）
+		if entry.LSN <= s.lsn {
+			return nil
+		}
+		if entry.LSN != s.lsn+1 {
+			return fmt.Errorf("replay wal: expected lsn %d, got %d (snapshot missing?)", s.lsn+1, entry.LSN)
+		}
+		return s.replayEntry(entry)
+	})
+	if err != nil {
+		_ = w.close()
+		return err
+	}
+
+	s.wal = w
+	return nil
+}
+
+// Close 停止后台任务并关闭存储，释放日志文件等资源
+func (s *Store[T]) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.bgStop)
+	})
+	s.bgWG.Wait()
+
+	s.Lock()
+	defer s.Unlock()
+
+	for len(s.watchers) > 0 {
+		s.closeWatcherLocked(s.watchers[0])
+	}
+
+	if s.wal == nil {
+		return nil
+	}
+	err := s.wal.close()
+	s.wal = nil
+	return err
+}
+
+// reportError 将无法返回给调用方的错误交给 OnError，未配置时通过标准库 log 输出
+func (s *Store[T]) reportError(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+		return
+	}
+	log.Printf("easydb: %v", err)
+}
+
+// goBackground 启动一个随 Close 退出的后台任务
+func (s *Store[T]) goBackground(fn func(stop <-chan struct{})) {
+	s.bgWG.Add(1)
+	go func() {
+		defer s.bgWG.Done()
+		fn(s.bgStop)
+	}()
+}
+
 func (s *Store[T]) Insert(ctx context.Context, data T) (*types.Record[T], error) {
 	s.Lock()
 	defer s.Unlock()
 
-	id := s.idGen.Add(1)
+	return s.insertLocked(data)
+}
+
+// insertLocked 分配 ID 并插入新记录（调用方持有写锁）
+func (s *Store[T]) insertLocked(data T) (*types.Record[T], error) {
+	return s.insertRecordLocked(s.newRecord(data, s.options.DefaultTTL))
+}
+
+// InsertWithTTL 插入一条在 ttl 后过期的记录，ttl <= 0 表示永不过期（不使用 DefaultTTL）
+func (s *Store[T]) InsertWithTTL(ctx context.Context, data T, ttl time.Duration) (*types.Record[T], error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.insertRecordLocked(s.newRecord(data, ttl))
+}
+
+// insertRecordLocked 校验并写入新构造的记录（调用方持有写锁）
+func (s *Store[T]) insertRecordLocked(record *types.Record[T]) (*types.Record[T], error) {
+	if err := s.reapForUniqueLocked(); err != nil {
+		return nil, err
+	}
+	if err := s.IndexManager.CheckUnique(map[uint64]*types.Record[T]{record.ID: record}); err != nil {
+		return nil, err
+	}
+	if err := s.appendWAL(walOpInsert, record); err != nil {
+		return nil, err
+	}
+	s.applyInsert(record)
+	s.enforceLimitsLocked(map[uint64]*types.Record[T]{record.ID: record})
+
+	return s.export(record), nil
+}
+
+// newRecord 分配 ID 并构造一条新记录，尚未写入存储；ttl > 0 时设置过期时间
+func (s *Store[T]) newRecord(data T, ttl time.Duration) *types.Record[T] {
 	now := time.Now().UnixNano()
 	record := &types.Record[T]{
-		ID:      id,
-		Data:    data,
+		ID:      s.idGen.Add(1),
+		Data:    s.importData(data),
 		Version: 1,
 		Meta: types.RecordMeta{
 			CreatedAt: now,
 			UpdatedAt: now,
 		},
 	}
-
-	index := len(s.data)
-	s.data = append(s.data, record)
-	s.idMapIndex[id] = index
-	s.addAliveIndex(index)
-
-	s.IndexManager.AddIndexByRecord(record)
-
-	return record, nil
+	if ttl > 0 {
+		record.Meta.ExpiresAt = now + int64(ttl)
+	}
+	return record
 }
 
 func (s *Store[T]) Get(ctx context.Context, id uint64) (*types.Record[T], error) {
@@ -78,61 +283,121 @@ func (s *Store[T]) Get(ctx context.Context, id uint64) (*types.Record[T], error)
 	defer s.RUnlock()
 
 	idx, ok := s.idMapIndex[id]
-	if !ok || s.data[idx].Meta.Deleted {
+	if !ok || s.data[idx].Meta.Deleted || s.data[idx].Meta.Expired(time.Now().UnixNano()) {
 		return nil, errors.ErrNotFound
 	}
+	if s.evictor != nil {
+		s.evictor.touch(id)
+	}
 
-	return s.data[idx], nil
+	return s.export(s.data[idx]), nil
 }
 
 func (s *Store[T]) Update(ctx context.Context, id uint64, data T) (*types.Record[T], error) {
 	s.Lock()
 	defer s.Unlock()
 
+	return s.updateLocked(id, data, nil)
+}
+
+// UpdateIfVersion 仅当记录当前版本等于 expectedVersion 时更新，否则返回 ErrVersionConflict。
+// 需要启用 EnableVersioning。
+func (s *Store[T]) UpdateIfVersion(ctx context.Context, id uint64, expectedVersion uint64, data T) (*types.Record[T], error) {
+	if !s.options.EnableVersioning {
+		return nil, fmt.Errorf("%w: versioning is disabled", errors.ErrInvalidInput)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.updateLocked(id, data, &expectedVersion)
+}
+
+// updateLocked 更新记录，expectedVersion 非 nil 时先校验版本（调用方持有写锁）
+func (s *Store[T]) updateLocked(id uint64, data T, expectedVersion *uint64) (*types.Record[T], error) {
+	if err := s.reapForUniqueLocked(); err != nil {
+		return nil, err
+	}
+
 	idx, ok := s.idMapIndex[id]
 	if !ok {
 		return nil, errors.ErrNotFound
 	}
 
 	record := s.data[idx]
-	if record.Meta.Deleted {
-		return nil, errors.ErrRecordDeleted
+	if err := liveErr(record, time.Now().UnixNano()); err != nil {
+		return nil, err
+	}
+	if expectedVersion != nil && record.Version != *expectedVersion {
+		return nil, errors.ErrVersionConflict
 	}
 
-	old := *record
-	record.Data = data
-	record.Meta.UpdatedAt = time.Now().UnixNano()
+	next := *record
+	next.Data = s.importData(data)
+	next.Meta.UpdatedAt = time.Now().UnixNano()
 	if s.options.EnableVersioning {
-		record.Version++
+		next.Version++
 	}
 
-	s.IndexManager.UpdateIndexByRecord(&old, record)
+	if err := s.IndexManager.CheckUnique(map[uint64]*types.Record[T]{id: &next}); err != nil {
+		return nil, err
+	}
+	if err := s.appendWAL(walOpUpdate, &next); err != nil {
+		return nil, err
+	}
+	updated := s.applyUpdate(idx, next)
+	s.enforceLimitsLocked(map[uint64]*types.Record[T]{id: updated})
 
-	return record, nil
+	return s.export(updated), nil
 }
 
 func (s *Store[T]) Delete(ctx context.Context, id uint64) error {
 	s.Lock()
 	defer s.Unlock()
 
+	return s.deleteLocked(id, nil)
+}
+
+// DeleteIfVersion 仅当记录当前版本等于 expectedVersion 时删除，否则返回 ErrVersionConflict。
+// 需要启用 EnableVersioning。
+func (s *Store[T]) DeleteIfVersion(ctx context.Context, id uint64, expectedVersion uint64) error {
+	if !s.options.EnableVersioning {
+		return fmt.Errorf("%w: versioning is disabled", errors.ErrInvalidInput)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.deleteLocked(id, &expectedVersion)
+}
+
+// deleteLocked 删除记录，expectedVersion 非 nil 时先校验版本（调用方持有写锁）
+func (s *Store[T]) deleteLocked(id uint64, expectedVersion *uint64) error {
 	idx, ok := s.idMapIndex[id]
 	if !ok {
 		return errors.ErrNotFound
 	}
 
 	record := s.data[idx]
-	if record.Meta.Deleted {
-		return errors.ErrRecordDeleted
+	if err := liveErr(record, time.Now().UnixNano()); err != nil {
+		return err
+	}
+	if expectedVersion != nil && record.Version != *expectedVersion {
+		return errors.ErrVersionConflict
 	}
 
-	record.Meta.Deleted = true
-	record.Meta.UpdatedAt = time.Now().UnixNano()
-	s.removeAliveIndex(idx)
+	next := *record
+	next.Meta.Deleted = true
+	next.Meta.UpdatedAt = time.Now().UnixNano()
 
-	s.IndexManager.RemoveIndexByRecord(record)
+	if err := s.appendWAL(walOpDelete, &next); err != nil {
+		return err
+	}
+	s.applyDelete(idx, next.Meta.UpdatedAt)
 	return nil
 }
 
+// List 按存储位置分页列出存活记录，返回当前页和总数，返回的记录与 Get 一样计为读取
 func (s *Store[T]) List(ctx context.Context, offset, limit int) ([]*types.Record[T], int, error) {
 	s.RLock()
 	defer s.RUnlock()
@@ -145,7 +410,9 @@ func (s *Store[T]) List(ctx context.Context, offset, limit int) ([]*types.Record
 		limit = 0
 	}
 
-	total := len(s.aliveIndexes)
+	now := time.Now().UnixNano()
+	expired := s.expiredPositionsLocked(now)
+	total := s.alive.Len() - len(expired)
 	if offset >= total {
 		return []*types.Record[T]{}, total, nil
 	}
@@ -156,46 +423,329 @@ func (s *Store[T]) List(ctx context.Context, offset, limit int) ([]*types.Record
 	}
 
 	records := make([]*types.Record[T], 0, end-offset)
-
-	for _, idx := range s.aliveIndexes[offset:end] {
-		rec := s.data[idx]
-		// 可以保留防御式检查，防止脏数据
-		if rec.Meta.Deleted {
+	for pos := s.selectVisibleLocked(offset, expired); pos >= 0 && len(records) < end-offset; pos = s.alive.Next(pos + 1) {
+		if _, ok := expired[pos]; ok {
 			continue
 		}
-		records = append(records, rec)
+		records = append(records, s.export(s.data[pos]))
+		if s.evictor != nil {
+			s.evictor.touch(s.data[pos].ID)
+		}
 	}
 
 	return records, total, nil
 }
 
+// selectVisibleLocked 返回跳过过期记录后排第 k 个的存活记录位置（调用方持有锁）。
+// 过期记录通常很少，先按排名定位，再根据排在前面的过期记录数向后修正。
+func (s *Store[T]) selectVisibleLocked(k int, expired map[int]struct{}) int {
+	skipped := 0
+	for {
+		pos := s.alive.Select(k + skipped)
+		if pos < 0 {
+			return -1
+		}
+		before := 0
+		for p := range expired {
+			if p <= pos {
+				before++
+			}
+		}
+		if before == skipped {
+			return pos
+		}
+		skipped = before
+	}
+}
+
+// appendLocked 将记录追加到 data 末尾并登记位置，返回该位置（调用方持有写锁）
+func (s *Store[T]) appendLocked(record *types.Record[T]) int {
+	index := len(s.data)
+	if index > 0 && s.data[index-1].ID > record.ID {
+		s.unordered = true
+	}
+	s.data = append(s.data, record)
+	s.idMapIndex[record.ID] = index
+	return index
+}
+
+// applyInsert 将新记录写入内存结构（调用方持有写锁）
+func (s *Store[T]) applyInsert(record *types.Record[T]) {
+	index := s.appendLocked(record)
+	s.addAliveIndex(index)
+
+	s.createdIndex.Insert(record.Meta.CreatedAt, record.ID)
+	s.updatedIndex.Insert(record.Meta.UpdatedAt, record.ID)
+	s.trackExpiry(record)
+	if s.evictor != nil {
+		s.evictor.added(record)
+	}
+	s.IndexManager.AddIndexByRecord(record)
+	s.commitVersion(record.ID, nil)
+}
+
+// applyUpdate 用新状态替换 data[idx] 处的记录并更新索引，返回新记录（调用方持有写锁）。
+// 已写入的记录对象不会再被修改，旧对象留给读视图和已返回给调用方的指针。
+func (s *Store[T]) applyUpdate(idx int, next types.Record[T]) *types.Record[T] {
+	old := s.data[idx]
+	record := &next
+	s.data[idx] = record
+	s.noteReplaced(record.ID)
+
+	s.updatedIndex.Delete(old.Meta.UpdatedAt, old.ID)
+	s.updatedIndex.Insert(record.Meta.UpdatedAt, record.ID)
+	if old.Meta.ExpiresAt != record.Meta.ExpiresAt {
+		s.untrackExpiry(old)
+		s.trackExpiry(record)
+	}
+	if s.evictor != nil {
+		s.evictor.updated(old, record)
+	}
+	s.IndexManager.UpdateIndexByRecord(old, record)
+	s.addHistory(old)
+	s.commitVersion(record.ID, old)
+	return record
+}
+
+// applyDelete 用标记为删除的副本替换 data[idx] 处的记录并移出索引（调用方持有写锁）
+func (s *Store[T]) applyDelete(idx int, deletedAt int64) {
+	old := s.data[idx]
+	s.createdIndex.Delete(old.Meta.CreatedAt, old.ID)
+	s.updatedIndex.Delete(old.Meta.UpdatedAt, old.ID)
+	s.untrackExpiry(old)
+	if s.evictor != nil {
+		s.evictor.removed(old)
+	}
+
+	record := *old
+	record.Meta.Deleted = true
+	record.Meta.UpdatedAt = deletedAt
+	s.data[idx] = &record
+	s.removeAliveIndex(idx)
+	s.noteReplaced(record.ID)
+	s.noteTombstone(deletedAt)
+
+	s.IndexManager.RemoveIndexByRecord(old)
+	s.addHistory(old)
+	s.commitVersion(record.ID, old)
+}
+
+// appendWAL 在修改内存之前追加日志，未启用持久化时直接返回（调用方持有写锁）
+func (s *Store[T]) appendWAL(op walOp, record *types.Record[T]) error {
+	return s.appendWALEntry(&walEntry[T]{Op: op, Record: *record})
+}
+
+// appendWALBatch 将多个操作作为一帧写入日志，回放时要么全部生效要么全部丢弃（调用方持有写锁）
+func (s *Store[T]) appendWALBatch(entries []walEntry[T]) error {
+	return s.appendWALEntry(&walEntry[T]{Op: walOpBatch, Batch: entries})
+}
+
+// appendWALEntry 分配序号并追加一条日志（调用方持有写锁）
+func (s *Store[T]) appendWALEntry(entry *walEntry[T]) error {
+	if s.wal == nil {
+		return nil
+	}
+
+	entry.LSN = s.lsn + 1
+	payload, err := encodeWALEntry(entry)
+	if err != nil {
+		return err
+	}
+	if err := s.wal.append(payload); err != nil {
+		return err
+	}
+	s.lsn = entry.LSN
+
+	if s.options.CompactLogSize > 0 && s.wal.size >= s.options.CompactLogSize {
+		select {
+		case s.compactCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// replayEntry 回放一条日志记录（仅在 Open 期间调用）
+func (s *Store[T]) replayEntry(entry *walEntry[T]) error {
+	if err := s.applyEntry(entry); err != nil {
+		return fmt.Errorf("replay wal lsn %d: %w", entry.LSN, err)
+	}
+	s.lsn = entry.LSN
+	return nil
+}
+
+// applyEntry 将一条日志操作应用到内存结构，并推进 idGen（调用方持有写锁）
+func (s *Store[T]) applyEntry(entry *walEntry[T]) error {
+	if entry.Op == walOpBatch {
+		for i := range entry.Batch {
+			if err := s.applyEntry(&entry.Batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	rec := entry.Record
+	idx, exists := s.idMapIndex[rec.ID]
+
+	switch entry.Op {
+	case walOpInsert:
+		if exists {
+			return fmt.Errorf("duplicate record id %d", rec.ID)
+		}
+		s.applyInsert(&rec)
+	case walOpUpdate:
+		if !exists || s.data[idx].Meta.Deleted {
+			return fmt.Errorf("update of missing record %d", rec.ID)
+		}
+		s.applyUpdate(idx, rec)
+	case walOpDelete:
+		if !exists || s.data[idx].Meta.Deleted {
+			return fmt.Errorf("delete of missing record %d", rec.ID)
+		}
+		s.applyDelete(idx, rec.Meta.UpdatedAt)
+	case walOpRestore:
+		switch {
+		case !exists:
+			// 墓碑不在快照中或已被回收，按新记录恢复
+			s.applyInsert(&rec)
+		case s.data[idx].Meta.Deleted:
+			s.applyRestore(idx, rec)
+		default:
+			return fmt.Errorf("restore of live record %d", rec.ID)
+		}
+	case walOpPurge:
+		if exists {
+			if !s.data[idx].Meta.Deleted {
+				return fmt.Errorf("purge of live record %d", rec.ID)
+			}
+			s.applyPurge(idx)
+		}
+	default:
+		return fmt.Errorf("unknown op %d", entry.Op)
+	}
+
+	if rec.ID > s.idGen.Load() {
+		s.idGen.Store(rec.ID)
+	}
+	return nil
+}
+
 // 添加活跃项（Insert 时调用）
 func (s *Store[T]) addAliveIndex(index int) {
-	s.aliveIndexes = append(s.aliveIndexes, index)
-	s.aliveIndexSet[index] = struct{}{}
+	s.alive.Add(index)
 }
 
-// 移除活跃项（Delete 时调用）
+// 移除活跃项（Delete 时调用），O(log n)
 func (s *Store[T]) removeAliveIndex(index int) {
-	delete(s.aliveIndexSet, index)
-	for i, idx := range s.aliveIndexes {
-		if idx == index {
-			s.aliveIndexes = append(s.aliveIndexes[:i], s.aliveIndexes[i+1:]...)
-			break
-		}
-	}
+	s.alive.Remove(index)
 }
 
+// Data 返回所有记录（包括已删除的墓碑）的新切片，安全读模式下记录也是副本
 func (s *Store[T]) Data() []*types.Record[T] {
 	s.RLock()
 	defer s.RUnlock()
-	return s.data
+
+	data := make([]*types.Record[T], len(s.data))
+	for i, rec := range s.data {
+		data[i] = s.export(rec)
+	}
+	return data
+}
+
+// export 返回交给调用方的记录：安全读模式下为副本，否则为内部记录本身
+func (s *Store[T]) export(record *types.Record[T]) *types.Record[T] {
+	if !s.options.SafeReads {
+		return record
+	}
+	return record.Clone()
+}
+
+// importData 返回写入存储的数据：安全读模式下为副本，避免调用方之后修改传入的数据
+func (s *Store[T]) importData(data T) T {
+	if !s.options.SafeReads {
+		return data
+	}
+	return types.CloneData(data)
 }
 
+// AliveIndexes 返回存活记录在 Data() 中的位置（按插入顺序，新分配）
 func (s *Store[T]) AliveIndexes() []int {
 	s.RLock()
 	defer s.RUnlock()
-	return s.aliveIndexes
+
+	indexes := make([]int, 0, s.alive.Len())
+	s.alive.Range(func(pos int) bool {
+		indexes = append(indexes, pos)
+		return true
+	})
+	return indexes
+}
+
+// QueryTimeRange 通过时间索引返回指定时间字段落在 [start, end] 内的存活记录 ID，
+// start 或 end 为 nil 表示该侧无界
+func (s *Store[T]) QueryTimeRange(field TimeField, start, end *time.Time) map[uint64]struct{} {
+	s.RLock()
+	defer s.RUnlock()
+
+	index := s.createdIndex
+	if field == TimeUpdated {
+		index = s.updatedIndex
+	}
+
+	var lo, hi interface{}
+	if start != nil {
+		lo = start.UnixNano()
+	}
+	if end != nil {
+		hi = end.UnixNano()
+	}
+
+	result := make(map[uint64]struct{})
+	index.Range(lo, hi, true, true, func(_ interface{}, ids map[uint64]struct{}) bool {
+		for id := range ids {
+			result[id] = struct{}{}
+		}
+		return true
+	})
+	return result
+}
+
+// Scan 按插入顺序遍历存活记录（跳过已过期的记录），fn 返回 false 时停止。
+// 遍历期间持有读锁，fn 中不能调用 Store 的写方法。为避免复制，fn 收到的是内部记录（安全读模式下也是），
+// 不能修改，也不能在 fn 返回后继续持有，需要保留时请使用 Get 或 Record.Clone。
+func (s *Store[T]) Scan(fn func(*types.Record[T]) bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	now := time.Now().UnixNano()
+	s.alive.Range(func(pos int) bool {
+		rec := s.data[pos]
+		return rec.Meta.Expired(now) || fn(rec)
+	})
+}
+
+// AliveIDs 返回所有存活记录 ID 的集合（新分配，调用方可修改）
+func (s *Store[T]) AliveIDs() map[uint64]struct{} {
+	s.RLock()
+	defer s.RUnlock()
+
+	now := time.Now().UnixNano()
+	ids := make(map[uint64]struct{}, s.alive.Len())
+	s.alive.Range(func(pos int) bool {
+		if rec := s.data[pos]; !rec.Meta.Expired(now) {
+			ids[rec.ID] = struct{}{}
+		}
+		return true
+	})
+	return ids
+}
+
+// AliveCount 返回存活（未删除且未过期）记录数
+func (s *Store[T]) AliveCount() int {
+	s.RLock()
+	defer s.RUnlock()
+	return s.alive.Len() - s.expiredCountLocked(time.Now().UnixNano())
 }
 
 func (s *Store[T]) Size() int {
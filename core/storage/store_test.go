@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalWritesCheckVersion(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{EnableVersioning: true})
+	defer s.Close()
+
+	rec, err := s.Insert(ctx, walItem{Name: "a"})
+	require.NoError(t, err)
+
+	updated, err := s.UpdateIfVersion(ctx, rec.ID, rec.Version, walItem{Name: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, rec.Version+1, updated.Version)
+
+	// 使用过期的版本号
+	_, err = s.UpdateIfVersion(ctx, rec.ID, rec.Version, walItem{Name: "c"})
+	assert.ErrorIs(t, err, errors.ErrVersionConflict)
+	assert.ErrorIs(t, s.DeleteIfVersion(ctx, rec.ID, rec.Version), errors.ErrVersionConflict)
+	got, err := s.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "b", got.Data.Name)
+
+	require.NoError(t, s.DeleteIfVersion(ctx, rec.ID, updated.Version))
+	_, err = s.Get(ctx, rec.ID)
+	assert.Error(t, err)
+
+	// 未启用版本控制时拒绝条件写入
+	plain := New[walItem](Options{})
+	defer plain.Close()
+	other, err := plain.Insert(ctx, walItem{Name: "a"})
+	require.NoError(t, err)
+	_, err = plain.UpdateIfVersion(ctx, other.ID, other.Version, walItem{Name: "b"})
+	assert.ErrorIs(t, err, errors.ErrInvalidInput)
+}
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/ds"
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// compactMinTombstones 自动压缩前至少积累的墓碑数，避免小数据量时频繁重建
+const compactMinTombstones = 1024
+
+// noteReplaced 记录 data 中的记录对象被替换：Compact 构建期间记下 ID，替换时改用最新对象（调用方持有写锁）
+func (s *Store[T]) noteReplaced(id uint64) {
+	if s.compacting {
+		s.compactReplaced = append(s.compactReplaced, id)
+	}
+}
+
+// noteTombstone 记录一次删除时间为 deletedAt 的删除，墓碑过多时通知后台压缩（调用方持有写锁）。
+// 上次压缩时因保留期留下的墓碑不计入比例，它们在保留期过后由 startTombstoneCompactor 按时间回收。
+func (s *Store[T]) noteTombstone(deletedAt int64) {
+	if s.oldestTombstone == 0 || deletedAt < s.oldestTombstone {
+		s.oldestTombstone = deletedAt
+	}
+
+	if s.options.TombstoneRatio <= 0 {
+		return
+	}
+	tombstones := len(s.data) - s.alive.Len() - s.keptTombstones
+	if tombstones >= compactMinTombstones && float64(tombstones) > s.options.TombstoneRatio*float64(len(s.data)) {
+		select {
+		case s.tombCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Compact 重写 data 去掉已删除的记录（墓碑），并重建 idMapIndex 和存活列表，返回回收的记录数。
+// 配置了 TombstoneRetention 时，删除时间在保留期内的墓碑会保留下来，仍可 Restore。
+// 新的 data 在读锁下构建，只阻塞写入；构建期间发生的写入在最后短暂的写锁内补齐后再替换，
+// 因此读者只在替换时被阻塞。记录对象本身不会被复制，已返回给调用方的指针和读视图仍然有效。
+func (s *Store[T]) Compact() int {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	s.Lock()
+	s.compacting = true
+	s.compactReplaced = nil
+	s.Unlock()
+
+	// 构建阶段：复制存活记录和保留期内的墓碑，按原顺序排列在新 data 的开头
+	s.RLock()
+	n := len(s.data)
+	count := s.alive.Len()
+	data := make([]*types.Record[T], 0, count+count/4+1)
+	idMap := make(map[uint64]int, count)
+	alive := ds.NewRankSet(cap(data))
+	keep := func(rec *types.Record[T], isAlive bool) {
+		idMap[rec.ID] = len(data)
+		if isAlive {
+			alive.Add(len(data))
+		}
+		data = append(data, rec)
+	}
+	if s.options.TombstoneRetention <= 0 {
+		s.alive.Range(func(pos int) bool {
+			keep(s.data[pos], true)
+			return true
+		})
+	} else {
+		cutoff := time.Now().UnixNano() - int64(s.options.TombstoneRetention)
+		for pos, rec := range s.data[:n] {
+			switch {
+			case s.alive.Contains(pos):
+				keep(rec, true)
+			case rec.Meta.UpdatedAt > cutoff && s.idMapIndex[rec.ID] == pos: // 已 Purge 的墓碑不在 idMapIndex 中
+				keep(rec, false)
+			}
+		}
+	}
+	s.RUnlock()
+
+	// 替换阶段：补上构建期间追加的记录，换上构建期间被替换的记录对象
+	s.Lock()
+	defer s.Unlock()
+
+	for _, rec := range s.data[n:] {
+		idMap[rec.ID] = len(data)
+		if !rec.Meta.Deleted {
+			alive.Add(len(data))
+		}
+		data = append(data, rec)
+	}
+	for _, id := range s.compactReplaced {
+		cur, exists := s.idMapIndex[id]
+		idx, ok := idMap[id]
+		switch {
+		case !exists: // 构建期间被 Purge，记录对象留到下次压缩
+			if ok {
+				alive.Remove(idx)
+				delete(idMap, id)
+			}
+		case !ok: // 构建时已被视为可回收的墓碑，之后又被恢复，追加到末尾
+			rec := s.data[cur]
+			idMap[id] = len(data)
+			if !rec.Meta.Deleted {
+				alive.Add(len(data))
+			}
+			data = append(data, rec)
+		default:
+			rec := s.data[cur]
+			data[idx] = rec
+			if rec.Meta.Deleted {
+				alive.Remove(idx)
+			} else {
+				alive.Add(idx)
+			}
+		}
+	}
+
+	// 按 ID 重新排序，之后 List 和无条件查询可以直接按位置分页
+	if !slices.IsSortedFunc(data, compareID[T]) {
+		data, idMap, alive = sortByID(data, idMap, alive)
+	}
+
+	// 被回收的记录不再能按 ID 访问，历史版本一并丢弃
+	for id := range s.history {
+		if _, ok := idMap[id]; !ok {
+			delete(s.history, id)
+		}
+	}
+
+	// 留下的墓碑（含构建期间新增和被 Purge 的）中最早的删除时间，决定下一次按保留期回收的时机
+	s.oldestTombstone = 0
+	for pos, rec := range data {
+		if !alive.Contains(pos) && (s.oldestTombstone == 0 || rec.Meta.UpdatedAt < s.oldestTombstone) {
+			s.oldestTombstone = rec.Meta.UpdatedAt
+		}
+	}
+
+	reclaimed := len(s.data) - len(data)
+	s.data = data
+	s.idMapIndex = idMap
+	s.alive = alive
+	s.keptTombstones = len(data) - alive.Len()
+	s.unordered = false
+	s.compacting = false
+	s.compactReplaced = nil
+	return reclaimed
+}
+
+// compareID 按记录 ID 比较
+func compareID[T any](a, b *types.Record[T]) int {
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// sortByID 将 Compact 构建的 data 按 ID 排序，并按新位置重建 idMap 和存活列表。
+// 构建期间被 Purge 的记录不在 idMap 中，排序后同样不登记。
+func sortByID[T any](data []*types.Record[T], idMap map[uint64]int, alive *ds.RankSet) ([]*types.Record[T], map[uint64]int, *ds.RankSet) {
+	order := make([]int, len(data))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return compareID(data[a], data[b]) })
+
+	sorted := make([]*types.Record[T], 0, cap(data))
+	sortedMap := make(map[uint64]int, len(idMap))
+	sortedAlive := ds.NewRankSet(cap(data))
+	for _, pos := range order {
+		rec := data[pos]
+		if idx, ok := idMap[rec.ID]; ok && idx == pos {
+			sortedMap[rec.ID] = len(sorted)
+		}
+		if alive.Contains(pos) {
+			sortedAlive.Add(len(sorted))
+		}
+		sorted = append(sorted, rec)
+	}
+	return sorted, sortedMap, sortedAlive
+}
+
+// Restore 恢复已删除的记录：重新加入存活列表和所有索引，更新 UpdatedAt（启用版本控制时版本加一）。
+// 记录已过期时恢复后不再过期。记录不存在或已被 Compact 回收时返回 ErrNotFound，
+// 唯一字段已被其他存活记录占用时返回 ErrDuplicateKey。
+func (s *Store[T]) Restore(ctx context.Context, id uint64) (*types.Record[T], error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.reapForUniqueLocked(); err != nil {
+		return nil, err
+	}
+
+	idx, ok := s.idMapIndex[id]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	record := s.data[idx]
+	if !record.Meta.Deleted {
+		return nil, fmt.Errorf("%w: record %d is not deleted", errors.ErrInvalidInput, id)
+	}
+
+	now := time.Now().UnixNano()
+	next := *record
+	next.Meta.Deleted = false
+	next.Meta.UpdatedAt = now
+	if next.Meta.Expired(now) {
+		next.Meta.ExpiresAt = 0
+	}
+	if s.options.EnableVersioning {
+		next.Version++
+	}
+
+	if err := s.IndexManager.CheckUnique(map[uint64]*types.Record[T]{id: &next}); err != nil {
+		return nil, err
+	}
+	if err := s.appendWAL(walOpRestore, &next); err != nil {
+		return nil, err
+	}
+	restored := s.applyRestore(idx, next)
+	s.enforceLimitsLocked(map[uint64]*types.Record[T]{id: restored})
+
+	return s.export(restored), nil
+}
+
+// ListDeleted 按删除前的插入顺序列出尚未回收的已删除记录，返回当前页和总数
+func (s *Store[T]) ListDeleted(ctx context.Context, offset, limit int) ([]*types.Record[T], int, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	records := make([]*types.Record[T], 0)
+	total := 0
+	for pos, rec := range s.data {
+		if !rec.Meta.Deleted || s.alive.Contains(pos) || s.idMapIndex[rec.ID] != pos {
+			continue
+		}
+		if total >= offset && len(records) < limit {
+			records = append(records, s.export(rec))
+		}
+		total++
+	}
+	return records, total, nil
+}
+
+// Purge 永久删除已删除的记录，之后无法 Restore，历史版本也一并丢弃。
+// 记录立即不可访问，占用的空间在下次 Compact 时释放。记录未删除时返回 ErrInvalidInput。
+func (s *Store[T]) Purge(ctx context.Context, id uint64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	idx, ok := s.idMapIndex[id]
+	if !ok {
+		return errors.ErrNotFound
+	}
+	record := s.data[idx]
+	if !record.Meta.Deleted {
+		return fmt.Errorf("%w: record %d is not deleted", errors.ErrInvalidInput, id)
+	}
+
+	if err := s.appendWAL(walOpPurge, record); err != nil {
+		return err
+	}
+	s.applyPurge(idx)
+	return nil
+}
+
+// applyRestore 用恢复后的状态替换 data[idx] 处的墓碑并重新加入各索引，返回新记录（调用方持有写锁）
+func (s *Store[T]) applyRestore(idx int, next types.Record[T]) *types.Record[T] {
+	old := s.data[idx]
+	record := &next
+	s.data[idx] = record
+	s.addAliveIndex(idx)
+	s.noteReplaced(record.ID)
+
+	s.createdIndex.Insert(record.Meta.CreatedAt, record.ID)
+	s.updatedIndex.Insert(record.Meta.UpdatedAt, record.ID)
+	s.trackExpiry(record)
+	if s.evictor != nil {
+		s.evictor.added(record)
+	}
+	s.IndexManager.AddIndexByRecord(record)
+	s.addHistory(old)
+	s.commitVersion(record.ID, old)
+	return record
+}
+
+// applyPurge 使 data[idx] 处的墓碑不可再按 ID 访问（调用方持有写锁）。
+// 墓碑对读视图本就不可见，不需要保留版本；记录对象留在 data 中，由 Compact 释放。
+func (s *Store[T]) applyPurge(idx int) {
+	id := s.data[idx].ID
+	delete(s.idMapIndex, id)
+	delete(s.history, id)
+	s.noteReplaced(id)
+}
+
+// tombstoneExpired 判断是否有墓碑已超过保留期，需要 Compact 回收
+func (s *Store[T]) tombstoneExpired(retention time.Duration) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.oldestTombstone != 0 && s.oldestTombstone <= time.Now().UnixNano()-int64(retention)
+}
+
+// startTombstoneCompactor 配置了 TombstoneRatio 或 TombstoneRetention 时启动后台墓碑压缩任务：
+// 墓碑比例超过阈值时立即压缩；配置了保留期时每隔 1/4 保留期（至少 1 秒）检查一次，
+// 最早的墓碑超过保留期后压缩，因此已删除记录最多在保留期之后再多留 1/4 保留期。
+func (s *Store[T]) startTombstoneCompactor() {
+	retention := s.options.TombstoneRetention
+	if s.options.TombstoneRatio <= 0 && retention <= 0 {
+		return
+	}
+
+	s.goBackground(func(stop <-chan struct{}) {
+		var tick <-chan time.Time
+		if retention > 0 {
+			ticker := time.NewTicker(max(retention/4, time.Second))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-s.tombCh:
+			case <-tick:
+				if !s.tombstoneExpired(retention) {
+					continue
+				}
+			}
+			s.Compact()
+			// 避免删除密集时连续重建
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionReclaimsTombstones(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.wal")
+	opts := Options{WALPath: path, TombstoneRetention: 50 * time.Millisecond}
+
+	s, err := Open[walItem](opts)
+	require.NoError(t, err)
+
+	var ids []uint64
+	for i := 0; i < 3; i++ {
+		rec, err := s.Insert(ctx, walItem{Name: "a", Age: i})
+		require.NoError(t, err)
+		ids = append(ids, rec.ID)
+	}
+	require.NoError(t, s.Delete(ctx, ids[0]))
+	require.NoError(t, s.Delete(ctx, ids[1]))
+
+	// 保留期内墓碑不会被回收，仍可恢复
+	assert.False(t, s.tombstoneExpired(opts.TombstoneRetention))
+	assert.Equal(t, 0, s.Compact())
+	_, err = s.Restore(ctx, ids[1])
+	require.NoError(t, err)
+
+	// 没有配置 TombstoneRatio，保留期过后也会由后台任务回收
+	require.Eventually(t, func() bool {
+		s.RLock()
+		defer s.RUnlock()
+		return len(s.data) == 2
+	}, 3*time.Second, 20*time.Millisecond)
+
+	s.RLock()
+	assert.Zero(t, s.oldestTombstone)
+	s.RUnlock()
+	deleted, total, err := s.ListDeleted(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+	assert.Zero(t, total)
+
+	// 回收后的快照不再包含墓碑
+	require.NoError(t, s.CompactLog())
+	require.NoError(t, s.Close())
+
+	s, err = Open[walItem](opts)
+	require.NoError(t, err)
+	defer s.Close()
+	s.RLock()
+	assert.Len(t, s.data, 2)
+	s.RUnlock()
+	assert.Equal(t, 2, s.AliveCount())
+}
+
+func TestNewStartsTombstoneCompactor(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{TombstoneRatio: 0.5})
+	defer s.Close()
+
+	items := make([]walItem, 2*compactMinTombstones)
+	results, err := s.InsertMany(ctx, items, true)
+	require.NoError(t, err)
+	ids := make([]uint64, 0, compactMinTombstones+100)
+	for _, r := range results[:cap(ids)] {
+		ids = append(ids, r.Record.ID)
+	}
+	_, err = s.DeleteMany(ctx, ids, true)
+	require.NoError(t, err)
+
+	// 没有 WAL 的存储同样由后台任务按比例回收墓碑
+	require.Eventually(t, func() bool {
+		s.RLock()
+		defer s.RUnlock()
+		return len(s.data) == len(items)-len(ids)
+	}, 3*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// defaultReapInterval 未配置 ReapInterval 时后台清理过期记录的间隔
+const defaultReapInterval = time.Second
+
+// trackExpiry 登记带过期时间的存活记录，数据加载完成后确保后台清理任务已启动（调用方持有写锁）
+func (s *Store[T]) trackExpiry(record *types.Record[T]) {
+	if record.Meta.ExpiresAt == 0 {
+		return
+	}
+	s.expiryIndex.Insert(record.Meta.ExpiresAt, record.ID)
+	if s.loaded {
+		s.reaperOnce.Do(s.startReaper)
+	}
+}
+
+// untrackExpiry 移除记录的过期登记（调用方持有写锁）
+func (s *Store[T]) untrackExpiry(record *types.Record[T]) {
+	if record.Meta.ExpiresAt != 0 {
+		s.expiryIndex.Delete(record.Meta.ExpiresAt, record.ID)
+	}
+}
+
+// expiredCountLocked 返回已过期但尚未清理的记录数（调用方持有锁）
+func (s *Store[T]) expiredCountLocked(now int64) int {
+	count := 0
+	s.expiryIndex.Range(nil, now, true, true, func(_ interface{}, ids map[uint64]struct{}) bool {
+		count += len(ids)
+		return true
+	})
+	return count
+}
+
+// expiredPositionsLocked 返回已过期但尚未清理的记录在 data 中的位置（调用方持有锁）
+func (s *Store[T]) expiredPositionsLocked(now int64) map[int]struct{} {
+	var positions map[int]struct{}
+	s.expiryIndex.Range(nil, now, true, true, func(_ interface{}, ids map[uint64]struct{}) bool {
+		if positions == nil {
+			positions = make(map[int]struct{})
+		}
+		for id := range ids {
+			positions[s.idMapIndex[id]] = struct{}{}
+		}
+		return true
+	})
+	return positions
+}
+
+// expiredIDsLocked 返回所有已过期但尚未清理的记录 ID（调用方持有锁）
+func (s *Store[T]) expiredIDsLocked(now int64) []uint64 {
+	var ids []uint64
+	s.expiryIndex.Range(nil, now, true, true, func(_ interface{}, set map[uint64]struct{}) bool {
+		for id := range set {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	return ids
+}
+
+// ReapExpired 立即软删除所有已过期的记录，返回删除的数量。
+// 删除与 DeleteMany 走相同的路径（写日志、移出所有索引），后台清理任务定期调用它。
+func (s *Store[T]) ReapExpired(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.reapLocked(time.Now().UnixNano())
+}
+
+// reapLocked 将 now 时已过期的记录作为一个批次删除（调用方持有写锁）
+func (s *Store[T]) reapLocked(now int64) (int, error) {
+	ids := s.expiredIDsLocked(now)
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	b := s.newBatch(len(ids), false)
+	for i, id := range ids {
+		if prev := b.current(id); prev != nil && !prev.Meta.Deleted {
+			_ = b.stageTombstone(i, prev)
+		}
+	}
+	if _, err := b.commit(); err != nil {
+		return 0, err
+	}
+	return len(b.ops), nil
+}
+
+// reapForUniqueLocked 在校验唯一约束前清理已过期的记录，避免不可见的记录占用唯一值（调用方持有写锁）
+func (s *Store[T]) reapForUniqueLocked() error {
+	if !s.IndexManager.hasUnique {
+		return nil
+	}
+	_, err := s.reapLocked(time.Now().UnixNano())
+	return err
+}
+
+// startReaper 启动后台过期清理任务，随 Close 退出
+func (s *Store[T]) startReaper() {
+	interval := s.options.ReapInterval
+	if interval <= 0 {
+		interval = defaultReapInterval
+	}
+
+	s.goBackground(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if _, err := s.ReapExpired(context.Background()); err != nil {
+				s.reportError(fmt.Errorf("reap expired: %w", err))
+			}
+		}
+	})
+}
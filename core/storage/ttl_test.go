@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiredRecordsAreNotWritable(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{ReapInterval: time.Hour})
+	defer s.Close()
+
+	rec, err := s.InsertWithTTL(ctx, walItem{Name: "a"}, time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	// 过期后、被清理之前，所有读写路径都视为不存在
+	_, err = s.Get(ctx, rec.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	_, err = s.Update(ctx, rec.ID, walItem{Name: "b"})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, rec.ID), errors.ErrNotFound)
+
+	results, err := s.UpdateMany(ctx, []BatchUpdate[walItem]{{ID: rec.ID, Data: walItem{Name: "b"}}}, false)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, errors.ErrNotFound)
+	results, err = s.DeleteMany(ctx, []uint64{rec.ID}, false)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, errors.ErrNotFound)
+
+	tx := s.Begin()
+	_, err = tx.Get(ctx, rec.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	_, err = tx.Update(ctx, rec.ID, walItem{Name: "b"})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.ErrorIs(t, tx.Delete(ctx, rec.ID), errors.ErrNotFound)
+	require.NoError(t, tx.Rollback())
+
+	n, err := s.ReapExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestEvictionRemovesExpiredRecords(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{ReapInterval: time.Hour, MaxRecords: 1})
+	defer s.Close()
+
+	old, err := s.InsertWithTTL(ctx, walItem{Name: "a"}, time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = s.Insert(ctx, walItem{Name: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.AliveCount())
+	_, err = s.Get(ctx, old.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// txBase 记录事务首次读取某条记录时的状态，提交时用于检测并发修改
+type txBase struct {
+	exists    bool
+	version   uint64
+	updatedAt int64
+	deleted   bool
+}
+
+// Tx 是 Store 上的事务：写操作先缓存在事务内，读操作能看到本事务的写入，
+// Commit 时在一次写锁内校验并整体生效（包括日志和所有索引），Rollback 直接丢弃缓存。
+// 事务读写过的已有记录若在提交前被其他操作修改，Commit 返回 ErrVersionConflict。
+// Tx 不是并发安全的，只应在一个 goroutine 中使用。
+type Tx[T any] struct {
+	store  *Store[T]
+	ops    []walEntry[T]               // 按顺序缓存的写操作
+	writes map[uint64]*types.Record[T] // 事务内每条记录的最新状态
+	bases  map[uint64]txBase           // 已有记录首次读取时的状态
+	done   bool
+}
+
+// Begin 开启一个事务
+func (s *Store[T]) Begin() *Tx[T] {
+	return &Tx[T]{
+		store:  s,
+		writes: make(map[uint64]*types.Record[T]),
+		bases:  make(map[uint64]txBase),
+	}
+}
+
+// Insert 在事务中插入一条记录，ID 立即分配，提交后生效
+func (tx *Tx[T]) Insert(ctx context.Context, data T) (*types.Record[T], error) {
+	if tx.done {
+		return nil, errors.ErrTxDone
+	}
+
+	record := tx.store.newRecord(data, tx.store.options.DefaultTTL)
+	tx.stage(walOpInsert, record)
+	return record.Clone(), nil
+}
+
+// Get 读取一条记录，优先返回本事务内的写入
+func (tx *Tx[T]) Get(ctx context.Context, id uint64) (*types.Record[T], error) {
+	if tx.done {
+		return nil, errors.ErrTxDone
+	}
+
+	record := tx.lookup(id)
+	if liveErr(record, time.Now().UnixNano()) != nil {
+		return nil, errors.ErrNotFound
+	}
+	return record.Clone(), nil
+}
+
+// Update 在事务中更新一条记录
+func (tx *Tx[T]) Update(ctx context.Context, id uint64, data T) (*types.Record[T], error) {
+	if tx.done {
+		return nil, errors.ErrTxDone
+	}
+
+	record := tx.lookup(id)
+	if err := liveErr(record, time.Now().UnixNano()); err != nil {
+		return nil, err
+	}
+
+	next := *record
+	next.Data = tx.store.importData(data)
+	next.Meta.UpdatedAt = time.Now().UnixNano()
+	if tx.store.options.EnableVersioning {
+		next.Version++
+	}
+
+	tx.stage(walOpUpdate, &next)
+	return next.Clone(), nil
+}
+
+// Delete 在事务中删除一条记录
+func (tx *Tx[T]) Delete(ctx context.Context, id uint64) error {
+	if tx.done {
+		return errors.ErrTxDone
+	}
+
+	record := tx.lookup(id)
+	if err := liveErr(record, time.Now().UnixNano()); err != nil {
+		return err
+	}
+
+	next := *record
+	next.Meta.Deleted = true
+	next.Meta.UpdatedAt = time.Now().UnixNano()
+
+	tx.stage(walOpDelete, &next)
+	return nil
+}
+
+// Commit 在一次写锁内校验并应用事务中的所有写操作，失败时不会留下任何部分修改
+func (tx *Tx[T]) Commit(ctx context.Context) error {
+	if tx.done {
+		return errors.ErrTxDone
+	}
+	tx.done = true
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	s := tx.store
+	s.Lock()
+	defer s.Unlock()
+
+	// 先清理过期记录，被清理的记录会在下面的冲突检测中体现
+	if err := s.reapForUniqueLocked(); err != nil {
+		return err
+	}
+	for id, base := range tx.bases {
+		if !matchesBase(base, s.recordLocked(id)) {
+			return fmt.Errorf("%w: record %d", errors.ErrVersionConflict, id)
+		}
+	}
+
+	if err := s.IndexManager.CheckUnique(tx.writes); err != nil {
+		return err
+	}
+	if err := s.appendWALBatch(tx.ops); err != nil {
+		return err
+	}
+	for i := range tx.ops {
+		if err := s.applyEntry(&tx.ops[i]); err != nil {
+			// 校验已保证不会发生，出现即为内部状态错误
+			panic(fmt.Sprintf("storage: apply committed tx: %v", err))
+		}
+	}
+	s.enforceLimitsLocked(tx.writes)
+	return nil
+}
+
+// Rollback 丢弃事务中缓存的所有写操作
+func (tx *Tx[T]) Rollback() error {
+	if tx.done {
+		return errors.ErrTxDone
+	}
+	tx.done = true
+	tx.ops = nil
+	tx.writes = nil
+	return nil
+}
+
+// stage 缓存一次写操作
+func (tx *Tx[T]) stage(op walOp, record *types.Record[T]) {
+	tx.ops = append(tx.ops, walEntry[T]{Op: op, Record: *record})
+	tx.writes[record.ID] = record
+}
+
+// lookup 返回记录在本事务视角下的最新状态（可能已删除或已过期），不存在时返回 nil，
+// 首次读取已有记录时记下其状态
+func (tx *Tx[T]) lookup(id uint64) *types.Record[T] {
+	if record, ok := tx.writes[id]; ok {
+		return record
+	}
+
+	s := tx.store
+	s.RLock()
+	current := s.recordLocked(id)
+	var record *types.Record[T]
+	if current != nil {
+		copied := *current
+		record = &copied
+	}
+	s.RUnlock()
+
+	if _, seen := tx.bases[id]; !seen {
+		tx.bases[id] = newTxBase(record)
+	}
+	return record
+}
+
+// recordLocked 返回 ID 对应的记录（含已删除的），不存在时返回 nil（调用方持有锁）
+func (s *Store[T]) recordLocked(id uint64) *types.Record[T] {
+	idx, ok := s.idMapIndex[id]
+	if !ok {
+		return nil
+	}
+	return s.data[idx]
+}
+
+// newTxBase 根据记录当前状态生成冲突检测基准
+func newTxBase[T any](record *types.Record[T]) txBase {
+	if record == nil {
+		return txBase{}
+	}
+	return txBase{
+		exists:    true,
+		version:   record.Version,
+		updatedAt: record.Meta.UpdatedAt,
+		deleted:   record.Meta.Deleted,
+	}
+}
+
+// matchesBase 判断记录是否仍处于基准状态
+func matchesBase[T any](b txBase, record *types.Record[T]) bool {
+	if record == nil {
+		return !b.exists
+	}
+	return b.exists &&
+		record.Version == b.version &&
+		record.Meta.UpdatedAt == b.updatedAt &&
+		record.Meta.Deleted == b.deleted
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTxStore(t *testing.T) *Store[walItem] {
+	t.Helper()
+	s := New[walItem](Options{})
+	s.IndexManager.Register("Name", func(r *types.Record[walItem]) interface{} { return r.Data.Name }, IndexExact)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestTxCommit(t *testing.T) {
+	ctx := context.Background()
+	s := newTxStore(t)
+	existing, err := s.Insert(ctx, walItem{Name: "old", Age: 1})
+	require.NoError(t, err)
+	doomed, err := s.Insert(ctx, walItem{Name: "doomed"})
+	require.NoError(t, err)
+
+	tx := s.Begin()
+	inserted, err := tx.Insert(ctx, walItem{Name: "new", Age: 2})
+	require.NoError(t, err)
+	_, err = tx.Update(ctx, existing.ID, walItem{Name: "changed", Age: 3})
+	require.NoError(t, err)
+	require.NoError(t, tx.Delete(ctx, doomed.ID))
+
+	// 事务内能读到自己的写入，提交前对外不可见
+	got, err := tx.Get(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", got.Data.Name)
+	_, err = tx.Get(ctx, doomed.ID)
+	assert.Error(t, err)
+	_, err = s.Get(ctx, inserted.ID)
+	assert.Error(t, err)
+	got, err = s.Get(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "old", got.Data.Name)
+
+	require.NoError(t, tx.Commit(ctx))
+
+	got, err = s.Get(ctx, inserted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new", got.Data.Name)
+	got, err = s.Get(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", got.Data.Name)
+	_, err = s.Get(ctx, doomed.ID)
+	assert.Error(t, err)
+
+	// 索引随提交一起更新
+	assert.Len(t, s.IndexManager.Query("Name", "changed"), 1)
+	assert.Empty(t, s.IndexManager.Query("Name", "old"))
+	assert.Empty(t, s.IndexManager.Query("Name", "doomed"))
+	assert.Len(t, s.IndexManager.Query("Name", "new"), 1)
+
+	assert.ErrorIs(t, tx.Commit(ctx), errors.ErrTxDone)
+}
+
+func TestTxWriteWriteConflict(t *testing.T) {
+	ctx := context.Background()
+	s := newTxStore(t)
+	rec, err := s.Insert(ctx, walItem{Name: "a"})
+	require.NoError(t, err)
+
+	tx := s.Begin()
+	inserted, err := tx.Insert(ctx, walItem{Name: "from tx"})
+	require.NoError(t, err)
+	_, err = tx.Update(ctx, rec.ID, walItem{Name: "tx"})
+	require.NoError(t, err)
+
+	// 提交前记录被其他操作修改
+	_, err = s.Update(ctx, rec.ID, walItem{Name: "other"})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, tx.Commit(ctx), errors.ErrVersionConflict)
+
+	// 整个事务不生效
+	got, err := s.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "other", got.Data.Name)
+	_, err = s.Get(ctx, inserted.ID)
+	assert.Error(t, err)
+	assert.Empty(t, s.IndexManager.Query("Name", "from tx"))
+
+	// 只读过的记录同样参与冲突检测
+	tx = s.Begin()
+	_, err = tx.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	_, err = tx.Insert(ctx, walItem{Name: "b"})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(ctx, rec.ID))
+	assert.ErrorIs(t, tx.Commit(ctx), errors.ErrVersionConflict)
+}
+
+func TestTxRollback(t *testing.T) {
+	ctx := context.Background()
+	s := newTxStore(t)
+	rec, err := s.Insert(ctx, walItem{Name: "a"})
+	require.NoError(t, err)
+
+	tx := s.Begin()
+	inserted, err := tx.Insert(ctx, walItem{Name: "b"})
+	require.NoError(t, err)
+	_, err = tx.Update(ctx, rec.ID, walItem{Name: "c"})
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	_, err = s.Get(ctx, inserted.ID)
+	assert.Error(t, err)
+	got, err := s.Get(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "a", got.Data.Name)
+	assert.Equal(t, 1, s.AliveCount())
+
+	assert.ErrorIs(t, tx.Rollback(), errors.ErrTxDone)
+	assert.ErrorIs(t, tx.Commit(ctx), errors.ErrTxDone)
+}
@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/ldChengYi/EasyDB/core/errors"
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// viewScanChunk ReadView 遍历时每次持有读锁解析的记录数，避免长时间阻塞写入
+const viewScanChunk = 1024
+
+// version 记录在某次修改之前的状态，按修改顺序从新到旧链接。
+// 序号小于 end 的读视图看到的是 record，nil 表示此时记录还不存在。
+type version[T any] struct {
+	record *types.Record[T]
+	end    uint64
+	prev   *version[T]
+}
+
+// retiredVersion 等待回收的旧版本：所有读视图的序号都不小于 end 时即可回收
+type retiredVersion struct {
+	end uint64
+	id  uint64
+}
+
+// commitVersion 提交一次修改：推进序号，通知订阅者，有读视图时保留修改前的状态 old（调用方持有写锁）
+func (s *Store[T]) commitVersion(id uint64, old *types.Record[T]) {
+	s.seq++
+	s.notifyWatchers(id, old)
+	if s.activeViews.Load() > 0 {
+		s.versions[id] = &version[T]{record: old, end: s.seq, prev: s.versions[id]}
+		s.retired = append(s.retired, retiredVersion{end: s.seq, id: id})
+	}
+	s.gcVersionsLocked()
+}
+
+// minPinnedSeq 返回打开的读视图中最小的序号，没有读视图时返回 math.MaxUint64
+func (s *Store[T]) minPinnedSeq() uint64 {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+
+	min := uint64(math.MaxUint64)
+	for seq := range s.pins {
+		if seq < min {
+			min = seq
+		}
+	}
+	return min
+}
+
+// gcVersionsLocked 回收所有读视图都不再需要的旧版本（调用方持有写锁）
+func (s *Store[T]) gcVersionsLocked() {
+	if len(s.retired) == 0 {
+		return
+	}
+
+	min := s.minPinnedSeq()
+	n := 0
+	for n < len(s.retired) && s.retired[n].end <= min {
+		s.truncateVersions(s.retired[n].id, min)
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	if n == len(s.retired) {
+		s.retired = s.retired[:0]
+		return
+	}
+	s.retired = append(s.retired[:0], s.retired[n:]...)
+}
+
+// truncateVersions 去掉记录版本链中失效序号不大于 min 的节点（调用方持有写锁）
+func (s *Store[T]) truncateVersions(id uint64, min uint64) {
+	node, ok := s.versions[id]
+	if !ok {
+		return
+	}
+	if node.end <= min {
+		delete(s.versions, id)
+		return
+	}
+	for node.prev != nil && node.prev.end > min {
+		node = node.prev
+	}
+	node.prev = nil
+}
+
+// visibleLocked 返回序号为 seq 的读视图看到的记录状态（可能已删除），不存在时返回 nil（调用方持有锁）
+func (s *Store[T]) visibleLocked(id uint64, seq uint64) *types.Record[T] {
+	record := s.recordLocked(id)
+	for node := s.versions[id]; node != nil && node.end > seq; node = node.prev {
+		record = node.record
+	}
+	return record
+}
+
+// ReadView 是存储在某一时刻的只读一致视图。
+// 视图打开期间的写入不会影响通过它读到的内容，被修改或删除的记录会保留旧版本，
+// 直到没有视图需要时回收。视图不阻塞写入，用完后必须调用 Close 释放旧版本。
+type ReadView[T any] struct {
+	store  *Store[T]
+	seq    uint64
+	at     int64 // 打开时间，判断记录是否过期的基准
+	closed atomic.Bool
+}
+
+// ReadView 打开一个固定在当前状态的读视图
+func (s *Store[T]) ReadView() *ReadView[T] {
+	s.RLock()
+	defer s.RUnlock()
+
+	s.viewMu.Lock()
+	s.pins[s.seq]++
+	s.activeViews.Add(1)
+	s.viewMu.Unlock()
+
+	return &ReadView[T]{store: s, seq: s.seq, at: time.Now().UnixNano()}
+}
+
+// Close 关闭视图，允许回收它固定的旧版本，重复调用无效果
+func (v *ReadView[T]) Close() {
+	if !v.closed.CompareAndSwap(false, true) {
+		return
+	}
+
+	s := v.store
+	s.viewMu.Lock()
+	if s.pins[v.seq]--; s.pins[v.seq] == 0 {
+		delete(s.pins, v.seq)
+	}
+	s.activeViews.Add(-1)
+	s.viewMu.Unlock()
+
+	// 写入繁忙时交给下一次修改回收
+	if s.TryLock() {
+		s.gcVersionsLocked()
+		s.Unlock()
+	}
+}
+
+// Seq 返回视图固定的提交序号
+func (v *ReadView[T]) Seq() uint64 {
+	return v.seq
+}
+
+// visible 判断视图中的记录是否存活（未删除且在视图打开时未过期）
+func (v *ReadView[T]) visible(record *types.Record[T]) bool {
+	return record != nil && !record.Meta.Deleted && !record.Meta.Expired(v.at)
+}
+
+// Get 获取视图中的记录，与 Store.Get 一样计为一次读取（EvictLeastRecentlyRead 策略），
+// 查询通过它取得结果，因此查询结果同样会刷新淘汰顺序
+func (v *ReadView[T]) Get(ctx context.Context, id uint64) (*types.Record[T], error) {
+	s := v.store
+	s.RLock()
+	defer s.RUnlock()
+
+	record := s.visibleLocked(id, v.seq)
+	if !v.visible(record) {
+		return nil, errors.ErrNotFound
+	}
+	if s.evictor != nil {
+		s.evictor.touch(id)
+	}
+	return s.export(record), nil
+}
+
+// Peek 返回视图中存活的内部记录，不存在时返回 nil。
+// 与 Get 不同，Peek 不计为读取，也不复制记录，用于在返回结果之前筛选候选记录；
+// 与 Scan 一样，收到的记录不能修改，需要交给调用方时请使用 Get。
+func (v *ReadView[T]) Peek(id uint64) *types.Record[T] {
+	s := v.store
+	s.RLock()
+	defer s.RUnlock()
+
+	record := s.visibleLocked(id, v.seq)
+	if !v.visible(record) {
+		return nil
+	}
+	return record
+}
+
+// Changed 返回视图打开之后被修改过（含插入、删除）的记录 ID（新分配）。
+// 其余记录在视图中的状态与当前状态相同，可以直接使用当前的索引查询结果。
+func (v *ReadView[T]) Changed() map[uint64]struct{} {
+	s := v.store
+	s.RLock()
+	defer s.RUnlock()
+
+	return v.changedLocked()
+}
+
+// changedLocked 返回视图打开之后被修改过的记录 ID（调用方持有锁）
+func (v *ReadView[T]) changedLocked() map[uint64]struct{} {
+	changed := make(map[uint64]struct{})
+	if v.store.seq == v.seq {
+		return changed
+	}
+	for id, node := range v.store.versions {
+		if node.end > v.seq {
+			changed[id] = struct{}{}
+		}
+	}
+	return changed
+}
+
+// candidateIDs 返回视图中可能存活的记录 ID：当前存活的记录加上视图打开之后被修改过的记录
+func (v *ReadView[T]) candidateIDs() []uint64 {
+	s := v.store
+	s.RLock()
+	defer s.RUnlock()
+
+	changed := v.changedLocked()
+	ids := make([]uint64, 0, s.alive.Len()+len(changed))
+	s.alive.Range(func(pos int) bool {
+		id := s.data[pos].ID
+		if _, ok := changed[id]; !ok {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	for id := range changed {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// scan 分批解析 ids 在视图中的存活记录并交给 fn，fn 在锁外调用，返回 false 时停止
+func (v *ReadView[T]) scan(ids []uint64, fn func(*types.Record[T]) bool) {
+	s := v.store
+	batch := make([]*types.Record[T], 0, viewScanChunk)
+	for start := 0; start < len(ids); start += viewScanChunk {
+		end := min(start+viewScanChunk, len(ids))
+
+		batch = batch[:0]
+		s.RLock()
+		for _, id := range ids[start:end] {
+			if record := s.visibleLocked(id, v.seq); v.visible(record) {
+				batch = append(batch, record)
+			}
+		}
+		s.RUnlock()
+
+		for _, record := range batch {
+			if !fn(record) {
+				return
+			}
+		}
+	}
+}
+
+// Scan 遍历视图中的存活记录（顺序不保证），fn 返回 false 时停止。
+// 与 Store.Scan 不同，fn 在锁外调用，遍历期间写入不受影响，fn 中也可以调用 Store 的方法。
+// fn 收到的是内部记录（不会再被修改），不能修改它，需要交给调用方时请使用 Get 或 Record.Clone。
+func (v *ReadView[T]) Scan(fn func(*types.Record[T]) bool) {
+	v.scan(v.candidateIDs(), fn)
+}
+
+// IDs 返回视图中所有存活记录 ID 的集合（新分配，调用方可修改）
+func (v *ReadView[T]) IDs() map[uint64]struct{} {
+	ids := make(map[uint64]struct{})
+	v.Scan(func(r *types.Record[T]) bool {
+		ids[r.ID] = struct{}{}
+		return true
+	})
+	return ids
+}
+
+// List 按 ID 升序分页列出视图中的存活记录，返回当前页和总数，返回的记录与 Get 一样计为读取。
+// 视图打开后没有写入、且记录在 data 中按 ID 排列时直接按位置分页（与 Store.List 相同），
+// 否则需要遍历整个视图后排序。
+func (v *ReadView[T]) List(ctx context.Context, offset, limit int) ([]*types.Record[T], int, error) {
+	s := v.store
+	s.RLock()
+	unchanged := s.seq == v.seq && !s.unordered
+	s.RUnlock()
+	if unchanged {
+		records, total, err := s.List(ctx, offset, limit)
+		// List 期间没有写入，读到的就是视图的状态，返回的记录已由 Store.List 计为读取
+		s.RLock()
+		unchanged = s.seq == v.seq
+		s.RUnlock()
+		if unchanged {
+			return records, total, err
+		}
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	var all []*types.Record[T]
+	v.Scan(func(r *types.Record[T]) bool {
+		all = append(all, r)
+		return true
+	})
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+
+	total := len(all)
+	if offset >= total {
+		return []*types.Record[T]{}, total, nil
+	}
+	end := min(offset+limit, total)
+	records := make([]*types.Record[T], 0, end-offset)
+	for _, r := range all[offset:end] {
+		records = append(records, s.export(r))
+	}
+	v.touch(records)
+	return records, total, nil
+}
+
+// touch 将返回给调用方的记录计为一次读取
+func (v *ReadView[T]) touch(records []*types.Record[T]) {
+	s := v.store
+	if s.evictor == nil {
+		return
+	}
+	s.RLock()
+	defer s.RUnlock()
+	for _, r := range records {
+		s.evictor.touch(r.ID)
+	}
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// walOp 表示 WAL 中记录的操作类型
+type walOp uint8
+
+const (
+	walOpInsert  walOp = iota + 1 // 插入
+	walOpUpdate                   // 更新
+	walOpDelete                   // 删除
+	walOpBatch                    // 批量操作（事务），整体生效
+	walOpRestore                  // 恢复已删除的记录
+	walOpPurge                    // 永久删除已删除的记录
+)
+
+// walHeaderSize 每个日志帧的头部长度：4 字节负载长度 + 4 字节 CRC32 校验和
+const walHeaderSize = 8
+
+// walMaxFrameSize 单帧负载上限，超过则视为损坏数据
+const walMaxFrameSize = 1 << 30
+
+var walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// walEntry 是一条日志记录，保存操作完成后记录的完整状态，回放时直接覆盖即可。
+// 批量操作的子记录保存在 Batch 中，子记录不单独分配序号。
+type walEntry[T any] struct {
+	LSN    uint64          `json:"lsn,omitempty"`
+	Op     walOp           `json:"op"`
+	Record types.Record[T] `json:"record"`
+	Batch  []walEntry[T]   `json:"batch,omitempty"`
+}
+
+// wal 是追加写的预写日志文件，调用方负责加锁（Store 的写锁）
+type wal struct {
+	file       *os.File
+	path       string
+	size       int64
+	syncWrites bool
+}
+
+// openWAL 打开（或创建）日志文件
+func openWAL(path string, syncWrites bool) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open wal %s: %w", path, err)
+	}
+	return &wal{file: f, path: path, syncWrites: syncWrites}, nil
+}
+
+// append 写入一帧
+func (w *wal) append(payload []byte) error {
+	n, err := writeFrame(w.file, payload)
+	w.size += int64(n)
+	if err != nil {
+		// 写入了半帧时回退到写入前的位置，避免后续帧接在残缺帧之后
+		if n > 0 {
+			w.size -= int64(n)
+			_ = w.file.Truncate(w.size)
+			_, _ = w.file.Seek(w.size, io.SeekStart)
+		}
+		return fmt.Errorf("append wal: %w", err)
+	}
+
+	if w.syncWrites {
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("sync wal: %w", err)
+		}
+	}
+	return nil
+}
+
+// replay 从头读取所有完整且校验通过的帧并交给 fn 处理。
+// 遇到残缺或校验失败的尾部（进程在写入中途崩溃）时截断文件并停止，
+// 之后的写入会从最后一个完整帧之后继续。
+func (w *wal) replay(fn func(payload []byte) error) error {
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek wal: %w", err)
+	}
+
+	var offset int64
+	for {
+		payload, err := readFrame(w.file)
+		if err == io.EOF || err == errTornFrame {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read wal: %w", err)
+		}
+
+		if err := fn(payload); err != nil {
+			return err
+		}
+		offset += walHeaderSize + int64(len(payload))
+	}
+
+	// 丢弃最后一个完整帧之后的内容
+	if err := w.file.Truncate(offset); err != nil {
+		return fmt.Errorf("truncate wal: %w", err)
+	}
+	if _, err := w.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek wal: %w", err)
+	}
+	w.size = offset
+	return nil
+}
+
+// reset 清空日志文件（内容已写入快照之后调用）
+func (w *wal) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("truncate wal: %w", err)
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek wal: %w", err)
+	}
+	w.size = 0
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("sync wal: %w", err)
+	}
+	return nil
+}
+
+// close 同步并关闭日志文件
+func (w *wal) close() error {
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("sync wal: %w", err)
+	}
+	return w.file.Close()
+}
+
+// errTornFrame 表示帧不完整或校验失败
+var errTornFrame = errors.New("torn or corrupted frame")
+
+// writeFrame 写入一帧：[len][crc][payload]，返回实际写入的字节数
+func writeFrame(w io.Writer, payload []byte) (int, error) {
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(payload, walCRCTable))
+	copy(buf[walHeaderSize:], payload)
+	return w.Write(buf)
+}
+
+// readFrame 读取一帧，流正好结束时返回 io.EOF，帧残缺或校验失败时返回 errTornFrame
+func readFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errTornFrame
+		}
+		return nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	sum := binary.LittleEndian.Uint32(header[4:8])
+	if length > walMaxFrameSize {
+		return nil, errTornFrame
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errTornFrame
+		}
+		return nil, err
+	}
+	if crc32.Checksum(payload, walCRCTable) != sum {
+		return nil, errTornFrame
+	}
+	return payload, nil
+}
+
+// encodeWALEntry 将日志记录编码为帧负载
+func encodeWALEntry[T any](e *walEntry[T]) ([]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("encode wal entry: %w", err)
+	}
+	return payload, nil
+}
+
+// decodeWALEntry 从帧负载解码日志记录
+func decodeWALEntry[T any](payload []byte) (*walEntry[T], error) {
+	var e walEntry[T]
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("decode wal entry: %w", err)
+	}
+	return &e, nil
+}
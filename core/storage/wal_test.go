@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type walItem struct {
+	Name string
+	Age  int
+}
+
+func openWALStore(t *testing.T, path string) *Store[walItem] {
+	t.Helper()
+	s, err := Open[walItem](Options{WALPath: path})
+	require.NoError(t, err)
+	return s
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	return info.Size()
+}
+
+func TestReplayTruncatesTornFrame(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.wal")
+
+	s := openWALStore(t, path)
+	for i := 0; i < 3; i++ {
+		_, err := s.Insert(ctx, walItem{Name: "a", Age: i})
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Close())
+	size := fileSize(t, path)
+
+	// 模拟写入中途崩溃：头部声明 100 字节负载，实际只写了 10 字节
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	header := make([]byte, walHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], 100)
+	_, err = f.Write(append(header, make([]byte, 10)...))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s = openWALStore(t, path)
+	assert.Equal(t, 3, s.AliveCount())
+	assert.Equal(t, size, fileSize(t, path), "torn tail should be truncated")
+
+	// 截断后追加的帧接在最后一个完整帧之后，可以正常回放
+	_, err = s.Insert(ctx, walItem{Name: "b", Age: 3})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s = openWALStore(t, path)
+	defer s.Close()
+	assert.Equal(t, 4, s.AliveCount())
+}
+
+func TestReplaySkipsEntriesInSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.wal")
+
+	s := openWALStore(t, path)
+	first, err := s.Insert(ctx, walItem{Name: "a", Age: 1})
+	require.NoError(t, err)
+	_, err = s.Insert(ctx, walItem{Name: "b", Age: 2})
+	require.NoError(t, err)
+	_, err = s.Update(ctx, first.ID, walItem{Name: "a", Age: 10})
+	require.NoError(t, err)
+
+	// 模拟 CompactLog 在 rename 快照之后、截断日志之前崩溃
+	f, err := os.Create(s.snapshotPath())
+	require.NoError(t, err)
+	require.NoError(t, s.Snapshot(f))
+	require.NoError(t, f.Close())
+
+	// 快照之后的写入只存在于日志中
+	third, err := s.Insert(ctx, walItem{Name: "c", Age: 3})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s = openWALStore(t, path)
+	defer s.Close()
+	assert.Equal(t, 3, s.AliveCount())
+	assert.Equal(t, uint64(4), s.lsn)
+
+	got, err := s.Get(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 10, got.Data.Age)
+
+	got, err = s.Get(ctx, third.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "c", got.Data.Name)
+
+	// 新记录的 ID 接在恢复的记录之后
+	next, err := s.Insert(ctx, walItem{Name: "d", Age: 4})
+	require.NoError(t, err)
+	assert.Equal(t, third.ID+1, next.ID)
+}
+
+func TestReplayBatchIsAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.wal")
+
+	s := openWALStore(t, path)
+	_, err := s.Insert(ctx, walItem{Name: "a", Age: 1})
+	require.NoError(t, err)
+	before := fileSize(t, path)
+
+	items := make([]walItem, 5)
+	for i := range items {
+		items[i] = walItem{Name: "batch", Age: i}
+	}
+	_, err = s.InsertMany(ctx, items, true)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	after := fileSize(t, path)
+
+	// 批量操作只占一帧，截断在帧中间时整批丢弃
+	require.NoError(t, os.Truncate(path, before+(after-before)/2))
+
+	s = openWALStore(t, path)
+	assert.Equal(t, 1, s.AliveCount())
+	assert.Equal(t, before, fileSize(t, path))
+	require.NoError(t, s.Close())
+
+	// 帧完整时整批生效
+	path = filepath.Join(t.TempDir(), "db.wal")
+	s = openWALStore(t, path)
+	tx := s.Begin()
+	for i := 0; i < 3; i++ {
+		_, err = tx.Insert(ctx, walItem{Name: "tx", Age: i})
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit(ctx))
+	require.NoError(t, s.Close())
+
+	s = openWALStore(t, path)
+	defer s.Close()
+	assert.Equal(t, 3, s.AliveCount())
+	assert.Equal(t, uint64(1), s.lsn)
+}
+
+func TestReplayReportsLSNGap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.wal")
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	for _, lsn := range []uint64{1, 3} {
+		entry := &walEntry[walItem]{LSN: lsn, Op: walOpInsert}
+		entry.Record.ID = lsn
+		payload, err := encodeWALEntry(entry)
+		require.NoError(t, err)
+		_, err = writeFrame(f, payload)
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	_, err = Open[walItem](Options{WALPath: path})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected lsn 2, got 3")
+}
+
+func TestCompactLogRecovers(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.wal")
+
+	s := openWALStore(t, path)
+	var ids []uint64
+	for i := 0; i < 4; i++ {
+		rec, err := s.Insert(ctx, walItem{Name: "a", Age: i})
+		require.NoError(t, err)
+		ids = append(ids, rec.ID)
+	}
+	require.NoError(t, s.Delete(ctx, ids[0]))
+	require.NoError(t, s.CompactLog())
+	assert.Equal(t, int64(0), fileSize(t, path))
+
+	_, err := s.Update(ctx, ids[1], walItem{Name: "b", Age: 1})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s = openWALStore(t, path)
+	defer s.Close()
+	assert.Equal(t, 3, s.AliveCount())
+	_, err = s.Get(ctx, ids[0])
+	assert.Error(t, err)
+	got, err := s.Get(ctx, ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, "b", got.Data.Name)
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ldChengYi/EasyDB/core/types"
+)
+
+// ChangeOp 变更事件的类型
+type ChangeOp int
+
+const (
+	ChangeInsert  ChangeOp = iota + 1 // 插入
+	ChangeUpdate                      // 更新
+	ChangeDelete                      // 删除（含过期清理和容量淘汰）
+	ChangeRestore                     // 恢复已删除的记录
+)
+
+// String 返回变更类型的名称
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	case ChangeRestore:
+		return "restore"
+	default:
+		return "unknown"
+	}
+}
+
+// ChangeEvent 一次记录变更
+type ChangeEvent[T any] struct {
+	Op      ChangeOp
+	ID      uint64
+	Old     *types.Record[T] // 变更前的状态，插入时为 nil
+	New     *types.Record[T] // 变更后的状态，删除时为带删除标记的记录
+	Seq     uint64           // 提交序号，严格递增，与 ReadView.Seq 可比较
+	Dropped uint64           // WatchDrop 策略下，本事件之前因队列已满丢弃的事件数
+}
+
+// WatchPolicy 订阅者处理不过来（排队的事件达到 Buffer）时的处理策略。
+// 任何策略下写入都不会等待订阅者：事件先放入订阅者自己的队列，再由单独的 goroutine 在锁外送入通道。
+type WatchPolicy int
+
+const (
+	WatchQueue      WatchPolicy = iota // 不丢弃事件，队列不设上限，订阅者长期处理不过来时内存会持续增长
+	WatchDrop                          // 丢弃事件，在下一个送达的事件中通过 Dropped 告知丢弃数
+	WatchDisconnect                    // 关闭订阅通道，尚未送达的事件一并丢弃
+)
+
+// WatchOptions 订阅选项
+type WatchOptions struct {
+	// Buffer WatchDrop、WatchDisconnect 策略下排队等待送达的事件数上限，<=0 时使用 64
+	Buffer int
+
+	// Policy 排队的事件达到上限时的处理策略，默认 WatchQueue（不丢弃、不设上限）
+	Policy WatchPolicy
+}
+
+// watcher 一个变更订阅。订阅列表由 Store 的写锁保护，队列由 mu 保护：
+// 写入方在写锁内把事件放入队列，deliver 在锁外按顺序送入通道。
+type watcher[T any] struct {
+	ch     chan ChangeEvent[T]
+	filter func(*types.Record[T]) bool
+	policy WatchPolicy
+	limit  int
+
+	mu      sync.Mutex
+	queue   []ChangeEvent[T]
+	dropped uint64
+	closed  bool
+	wake    chan struct{} // 容量为 1，队列中有新事件时通知 deliver
+	done    chan struct{} // 订阅关闭时关闭，通知 deliver 退出
+}
+
+// Watch 订阅之后的记录变更，ctx 取消、Store 关闭或 WatchDisconnect 策略下处理不过来时关闭通道。
+// filter 不为 nil 时只发送变更前或变更后的状态满足 filter 的事件（因此能看到记录离开过滤范围）。
+// filter 在修改存储的写锁内调用，必须很快且不能调用 Store 的方法。
+// 事件按提交顺序送达，写入方不会等待订阅者，订阅者处理事件时可以调用 Store 的任意方法。
+func (s *Store[T]) Watch(ctx context.Context, filter func(*types.Record[T]) bool, opts WatchOptions) <-chan ChangeEvent[T] {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+
+	w := &watcher[T]{
+		ch:     make(chan ChangeEvent[T]),
+		filter: filter,
+		policy: opts.Policy,
+		limit:  opts.Buffer,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	s.Lock()
+	s.watchers = append(s.watchers, w)
+	s.Unlock()
+
+	s.goBackground(func(stop <-chan struct{}) {
+		s.deliver(ctx, w, stop)
+	})
+	return w.ch
+}
+
+// deliver 在锁外把订阅者队列中的事件依次送入通道，订阅关闭、ctx 取消或 Store 关闭时关闭通道并退出
+func (s *Store[T]) deliver(ctx context.Context, w *watcher[T], stop <-chan struct{}) {
+	defer func() {
+		s.Lock()
+		s.closeWatcherLocked(w)
+		s.Unlock()
+		close(w.ch)
+	}()
+
+	for {
+		w.mu.Lock()
+		if len(w.queue) == 0 {
+			w.mu.Unlock()
+			select {
+			case <-w.wake:
+				continue
+			case <-w.done:
+			case <-ctx.Done():
+			case <-stop:
+			}
+			return
+		}
+		event := w.queue[0]
+		w.queue[0] = ChangeEvent[T]{}
+		w.queue = w.queue[1:]
+		w.mu.Unlock()
+
+		select {
+		case w.ch <- event:
+		case <-w.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+// notifyWatchers 将刚提交的变更放入订阅者的队列（调用方持有写锁）
+func (s *Store[T]) notifyWatchers(id uint64, old *types.Record[T]) {
+	if len(s.watchers) == 0 {
+		return
+	}
+
+	record := s.recordLocked(id)
+	event := ChangeEvent[T]{ID: id, Old: old, New: record, Seq: s.seq}
+	switch {
+	case old == nil:
+		event.Op = ChangeInsert
+	case record.Meta.Deleted:
+		event.Op = ChangeDelete
+	case old.Meta.Deleted:
+		event.Op = ChangeRestore
+	default:
+		event.Op = ChangeUpdate
+	}
+
+	// 倒序遍历，WatchDisconnect 移除订阅者时不影响尚未遍历的部分
+	for i := len(s.watchers) - 1; i >= 0; i-- {
+		w := s.watchers[i]
+		if w.filter != nil && !(old != nil && w.filter(old)) && !w.filter(record) {
+			continue
+		}
+		s.enqueueLocked(w, event)
+	}
+}
+
+// enqueueLocked 按订阅策略把一个事件放入订阅者的队列，不会阻塞（调用方持有写锁）
+func (s *Store[T]) enqueueLocked(w *watcher[T], event ChangeEvent[T]) {
+	if event.Old != nil {
+		event.Old = s.export(event.Old)
+	}
+	event.New = s.export(event.New)
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	if w.policy != WatchQueue && len(w.queue) >= w.limit {
+		if w.policy == WatchDrop {
+			w.dropped++
+			w.mu.Unlock()
+			return
+		}
+		w.mu.Unlock()
+		s.closeWatcherLocked(w)
+		return
+	}
+	event.Dropped = w.dropped
+	w.dropped = 0
+	w.queue = append(w.queue, event)
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// closeWatcherLocked 停止订阅：移出订阅列表、丢弃尚未送达的事件并通知 deliver 关闭通道，
+// 重复调用无效果（调用方持有写锁）
+func (s *Store[T]) closeWatcherLocked(w *watcher[T]) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	w.queue = nil
+	close(w.done)
+	w.mu.Unlock()
+
+	for i, other := range s.watchers {
+		if other == w {
+			s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+			break
+		}
+	}
+}
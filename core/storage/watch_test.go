@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drain 读取通道直到关闭或超时，返回收到的事件
+func drain[T any](t *testing.T, ch <-chan ChangeEvent[T]) []ChangeEvent[T] {
+	t.Helper()
+	var events []ChangeEvent[T]
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		case <-timeout:
+			t.Fatal("watch channel not closed")
+		}
+	}
+}
+
+func TestWatchDoesNotBlockWriters(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{})
+	defer s.Close()
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := s.Watch(watchCtx, nil, WatchOptions{Buffer: 1})
+
+	items := make([]walItem, 10)
+	for i := range items {
+		items[i] = walItem{Name: "a", Age: i}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := s.InsertMany(ctx, items, true)
+		assert.NoError(t, err)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("InsertMany blocked on a slow watcher")
+	}
+
+	// 订阅者处理事件时可以调用 Store 的方法
+	for i := 0; i < len(items); i++ {
+		ev := <-events
+		assert.Equal(t, ChangeInsert, ev.Op)
+		assert.Equal(t, i, ev.New.Data.Age)
+		_, err := s.Get(ctx, ev.ID)
+		require.NoError(t, err)
+		_, err = s.Update(ctx, ev.ID, walItem{Name: "b", Age: i})
+		require.NoError(t, err)
+	}
+	for i := 0; i < len(items); i++ {
+		ev := <-events
+		assert.Equal(t, ChangeUpdate, ev.Op)
+	}
+
+	cancel()
+	assert.Empty(t, drain(t, events))
+}
+
+func TestWatchDropAndDisconnect(t *testing.T) {
+	ctx := context.Background()
+	s := New[walItem](Options{})
+	defer s.Close()
+
+	dropped := s.Watch(ctx, nil, WatchOptions{Buffer: 2, Policy: WatchDrop})
+	base := runtime.NumGoroutine()
+	disconnected := s.Watch(ctx, nil, WatchOptions{Buffer: 2, Policy: WatchDisconnect})
+
+	for i := 0; i < 10; i++ {
+		_, err := s.Insert(ctx, walItem{Name: "a", Age: i})
+		require.NoError(t, err)
+	}
+
+	// 断开后通道关闭，不需要取消 ctx 或关闭 Store，送达事件的 goroutine 也会退出
+	drain(t, disconnected)
+	s.RLock()
+	assert.Len(t, s.watchers, 1)
+	s.RUnlock()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > base && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), base)
+
+	// 读完排队的事件后再写入一条，丢弃数随它送达
+	var received, lost uint64
+	for idle := false; !idle; {
+		select {
+		case ev := <-dropped:
+			received++
+			lost += ev.Dropped
+		case <-time.After(100 * time.Millisecond):
+			idle = true
+		}
+	}
+	_, err := s.Insert(ctx, walItem{Name: "b"})
+	require.NoError(t, err)
+	ev := <-dropped
+	received++
+	lost += ev.Dropped
+	assert.Equal(t, "b", ev.New.Data.Name)
+	assert.Greater(t, lost, uint64(0))
+	assert.Equal(t, uint64(11), received+lost)
+
+	require.NoError(t, s.Close())
+	drain(t, dropped)
+}
@@ -4,9 +4,15 @@ package types
 type RecordMeta struct {
 	CreatedAt int64
 	UpdatedAt int64
+	ExpiresAt int64 // 过期时间（UnixNano），0 表示永不过期
 	Deleted   bool
 }
 
+// Expired 判断记录在 now（UnixNano）时是否已过期
+func (m RecordMeta) Expired(now int64) bool {
+	return m.ExpiresAt != 0 && m.ExpiresAt <= now
+}
+
 // Record 表示数据库中的一条记录
 type Record[T any] struct {
 	ID      uint64
@@ -14,3 +20,26 @@ type Record[T any] struct {
 	Version uint64
 	Meta    RecordMeta
 }
+
+// Cloner 由包含切片、map、指针等引用字段的数据类型实现，返回与原值不共享内存的深拷贝
+type Cloner[T any] interface {
+	Clone() T
+}
+
+// CloneData 复制数据：实现了 Cloner（值或指针接收者）时深拷贝，否则按值复制
+func CloneData[T any](data T) T {
+	if c, ok := any(data).(Cloner[T]); ok {
+		return c.Clone()
+	}
+	if c, ok := any(&data).(Cloner[T]); ok {
+		return c.Clone()
+	}
+	return data
+}
+
+// Clone 返回记录的副本，Data 通过 CloneData 复制
+func (r *Record[T]) Clone() *Record[T] {
+	out := *r
+	out.Data = CloneData(r.Data)
+	return &out
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 )
 
 func SafeToString(v any) (string, error) {
@@ -26,46 +27,114 @@ func SafeToString(v any) (string, error) {
 	}
 }
 
+// Compare 比较两个值的大小，返回 -1、0、1。
+// 支持整数、无符号整数、浮点数（不同数值类型之间可以互相比较）、字符串和 time.Time；
+// 不同类别的值（如数值和 time.Time）按 数值 < 字符串 < time.Time 排列，不会 panic，
+// 需要发现这类错误时使用 TryCompare。其他类型会 panic，调用前可用 CanCompare 检查。
 func Compare(a, b interface{}) int {
+	c, err := TryCompare(a, b)
+	if err == nil {
+		return c
+	}
+
+	ka, kb := compareKind(a), compareKind(b)
+	if ka == kindInvalid || kb == kindInvalid {
+		panic("unsupported type for compare")
+	}
+	return compareOrdered(int64(ka), int64(kb))
+}
+
+// TryCompare 与 Compare 相同，但两个值属于不同类别或类型不支持比较时返回错误
+func TryCompare(a, b interface{}) (int, error) {
+	ka, kb := compareKind(a), compareKind(b)
+	if ka == kindInvalid || kb == kindInvalid || ka != kb {
+		return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+	}
+
+	if ka == kindTime {
+		return a.(time.Time).Compare(b.(time.Time)), nil
+	}
+
 	va := reflect.ValueOf(a)
 	vb := reflect.ValueOf(b)
 
 	switch va.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		ai := va.Int()
-		bi := vb.Int()
-		if ai < bi {
-			return -1
-		} else if ai > bi {
-			return 1
+		switch vb.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if ai < 0 {
+				return -1, nil
+			}
+			return compareOrdered(uint64(ai), vb.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return compareOrdered(float64(ai), vb.Float()), nil
 		}
-		return 0
+		return compareOrdered(ai, vb.Int()), nil
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		au := va.Uint()
-		bu := vb.Uint()
-		if au < bu {
-			return -1
-		} else if au > bu {
-			return 1
+		switch vb.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if vb.Int() < 0 {
+				return 1, nil
+			}
+			return compareOrdered(au, uint64(vb.Int())), nil
+		case reflect.Float32, reflect.Float64:
+			return compareOrdered(float64(au), vb.Float()), nil
 		}
-		return 0
+		return compareOrdered(au, vb.Uint()), nil
 
 	case reflect.Float32, reflect.Float64:
 		af := va.Float()
-		bf := vb.Float()
-		if af < bf {
-			return -1
-		} else if af > bf {
-			return 1
+		switch vb.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareOrdered(af, float64(vb.Int())), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return compareOrdered(af, float64(vb.Uint())), nil
 		}
-		return 0
+		return compareOrdered(af, vb.Float()), nil
+	}
 
+	return strings.Compare(va.String(), vb.String()), nil
+}
+
+// 可比较值的类别，同一类别的值之间才能比较
+const (
+	kindInvalid = iota
+	kindNumber
+	kindString
+	kindTime
+)
+
+// compareKind 返回值所属的比较类别
+func compareKind(v interface{}) int {
+	if _, ok := v.(time.Time); ok {
+		return kindTime
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return kindNumber
 	case reflect.String:
-		as := va.String()
-		bs := vb.String()
-		return strings.Compare(as, bs)
+		return kindString
+	default:
+		return kindInvalid
 	}
+}
 
-	panic("unsupported type for compare")
+// CanCompare 判断值是否可以用 Compare 比较
+func CanCompare(v interface{}) bool {
+	return compareKind(v) != kindInvalid
+}
+
+func compareOrdered[N int64 | uint64 | float64](a, b N) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
 }